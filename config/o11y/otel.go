@@ -10,11 +10,14 @@ import (
 	"github.com/cenkalti/backoff/v4"
 	"github.com/rollbar/rollbar-go"
 	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
 
 	"github.com/circleci/ex/config/secret"
 	"github.com/circleci/ex/o11y"
 	"github.com/circleci/ex/o11y/otel"
+	"github.com/circleci/ex/o11y/otel/xray"
 )
 
 // OtelConfig contains all the things we need to configure for otel based instrumentation.
@@ -27,6 +30,9 @@ type OtelConfig struct {
 	// HTTPAuthorization is the authorization token to send with http requests
 	HTTPAuthorization secret.String
 
+	// Compression is the compression of exported spans, see otel.Config
+	Compression otel.Compression
+
 	Dataset string
 	// UseEnvironments will cause spans to be sent to the new honeycomb environments
 	UseEnvironments bool
@@ -36,9 +42,26 @@ type OtelConfig struct {
 
 	Test bool
 
+	// SyncExport exports spans as they end, for tests and local development only, see otel.Config
+	SyncExport bool
+
 	SampleTraces  bool
 	SampleKeyFunc func(map[string]interface{}) string
 	SampleRates   map[string]uint
+	// SampleKeyFields optionally declares the only fields SampleKeyFunc uses, see otel.Config
+	SampleKeyFields []string
+	// SampleSeed is optionally mixed into the sampling hash, see otel.Config
+	SampleSeed string
+
+	// Sampler is an optional head sampler, see otel.NewRatioSampler
+	Sampler sdktrace.Sampler
+	// PropagateSampleRate propagates the root sample rate to the rest of the trace, see otel.Config
+	PropagateSampleRate bool
+
+	// Propagators are optional additional propagators, see otel.Config
+	Propagators []propagation.TextMapPropagator
+	// XRay uses X-Ray compatible trace ids, and propagates the X-Amzn-Trace-Id header, see xray.Configure
+	XRay bool
 
 	Statsd                  string
 	StatsNamespace          string
@@ -53,6 +76,10 @@ type OtelConfig struct {
 	Service string
 	Mode    string
 
+	// OnExportError is optionally called with any error exporting spans, for instance if the collector
+	// is unreachable. Startup does not wait for, or fail because of, an unreachable collector.
+	OnExportError func(error)
+
 	// Metrics allows setting a custom metrics client. Typically, the default Statsd provider is preferred.
 	// The provided value will be closed by the cleanup function
 	Metrics o11y.ClosableMetricsProvider
@@ -101,6 +128,7 @@ func (o *OtelConfig) otel() otel.Config {
 		GrpcHostAndPort:   o.GrpcHostAndPort,
 		HTTPTracesURL:     o.HTTPTracesURL,
 		HTTPAuthorization: o.HTTPAuthorization,
+		Compression:       o.Compression,
 		Dataset:           o.Dataset,
 		ResourceAttributes: []attribute.KeyValue{
 			semconv.ServiceNameKey.String(o.Service),
@@ -116,15 +144,25 @@ func (o *OtelConfig) otel() otel.Config {
 
 		DisableText: o.DisableText,
 
-		SampleTraces:  o.SampleTraces,
-		SampleKeyFunc: o.SampleKeyFunc,
-		SampleRates:   o.SampleRates,
-
-		Test: o.Test,
+		SampleTraces:        o.SampleTraces,
+		SampleKeyFunc:       o.SampleKeyFunc,
+		SampleRates:         o.SampleRates,
+		SampleKeyFields:     o.SampleKeyFields,
+		SampleSeed:          o.SampleSeed,
+		Sampler:             o.Sampler,
+		PropagateSampleRate: o.PropagateSampleRate,
+		OnExportError:       o.OnExportError,
+		Propagators:         o.Propagators,
+
+		Test:       o.Test,
+		SyncExport: o.SyncExport,
 	}
 	if o.UseEnvironments {
 		cfg.ResourceAttributes = append(cfg.ResourceAttributes, attribute.Bool("meta.environments", true))
 	}
+	if o.XRay {
+		cfg = xray.Configure(cfg)
+	}
 	return cfg
 }
 
@@ -66,3 +66,19 @@ func TestSetup(t *testing.T) {
 		assert.Check(t, cmp.Contains(metric.Tags, "mytag:myvalue"))
 	})
 }
+
+func TestOtel_XRay(t *testing.T) {
+	ctx, cleanup, err := o11yconfig.Otel(context.Background(), o11yconfig.OtelConfig{
+		Service:     "test-service",
+		DisableText: true,
+		XRay:        true,
+	})
+	assert.NilError(t, err)
+	defer cleanup(ctx)
+
+	ctx, span := o11y.StartSpan(ctx, "span")
+	defer span.End()
+
+	headers := o11y.FromContext(ctx).Helpers().ExtractPropagation(ctx).Headers
+	assert.Check(t, cmp.Contains(headers.Get("X-Amzn-Trace-Id"), "Root=1-"+span.Context().TraceID[:8]+"-"))
+}
@@ -31,8 +31,9 @@ func NewTxManagerWithTestQuerier(db *sqlx.DB, tq func(Querier) Querier) *TxManag
 type queryFn func(context.Context, Querier) error
 
 // WithTx wraps f in an explicit o11y'd transaction, handling rollback
-// if f returns an error. It will retry the transaction a few times in the face of
-// ErrBadConn errors.
+// if f returns an error. Each attempt has a transaction span recording the tx.outcome,
+// see o11y.StartTxSpan, and any query spans in f are nested within it.
+// It will retry the transaction a few times in the face of ErrBadConn errors.
 // The length here is due to the internalised func, which we want to encapsulate
 // to avoid reuse, since it is highly coupled to the retry behaviour.
 //
@@ -40,10 +41,16 @@ type queryFn func(context.Context, Querier) error
 func (t *TxManager) WithTx(ctx context.Context, f queryFn) (err error) {
 	// Set up the main transaction function that we will retry on ErrBadCon
 	transaction := func() (err error) {
+		// the transaction span is the parent of any query spans in f
+		ctx, span := o11y.StartTxSpan(ctx, "db: transaction")
+		span.AddRawField("db.system", "postgresql")
+
 		tx, err := t.db.BeginTxx(ctx, nil)
 		if err != nil {
 			_, err = mapBadCon(err)
-			return fmt.Errorf("begin transaction: %w", err)
+			err = fmt.Errorf("begin transaction: %w", err)
+			span.Rollback(err)
+			return err
 		}
 
 		// This defer is to catch any error from the call to f to decide if we should commit
@@ -57,10 +64,12 @@ func (t *TxManager) WithTx(ctx context.Context, f queryFn) (err error) {
 				// a panic occurred, attempt a rollback and re-panic
 				// (it may already be rolled-back, so ignore this error)
 				_ = tx.Rollback()
+				span.Rollback(fmt.Errorf("panic: %v", p))
 				panic(p)
 			case badConn(err):
 				// We can't do anything else with a bad connection, and the db server
 				// will already have rolled back
+				span.Rollback(err)
 				return
 			case err != nil:
 				// Never commit on an error.
@@ -69,6 +78,7 @@ func (t *TxManager) WithTx(ctx context.Context, f queryFn) (err error) {
 				// (the library code already handles rollback in the context Done cases)
 				// This check is in case f returned an err different from the context error
 				if ctx.Err() != nil {
+					span.Rollback(err)
 					return
 				}
 				// something other than a context cancel went wrong, rollback and report any
@@ -76,12 +86,14 @@ func (t *TxManager) WithTx(ctx context.Context, f queryFn) (err error) {
 				if rErr := tx.Rollback(); rErr != nil {
 					o11y.AddField(ctx, "rollback_error", rErr)
 				}
+				span.Rollback(err)
 			case ctx.Err() != nil:
 				// This case is if f suppressed an error but the transaction ctx is still Done
 				// even if f appeared to have not seen any error we report the context cancellation
 				// so the calling code will at least be able to be aware that the transaction was
 				// rolled back
 				err = ctx.Err()
+				span.Rollback(err)
 			default:
 				// All good, commit
 				err = tx.Commit()
@@ -90,6 +102,7 @@ func (t *TxManager) WithTx(ctx context.Context, f queryFn) (err error) {
 				if err != nil {
 					err = fmt.Errorf("commit transaction: %w", err)
 				}
+				span.Commit(err)
 				// N.B there is no need for an explicit rollback - the db server automatically rolls back
 				// transactions where the connection (or session) is dropped (ErrBadConn).
 			}
@@ -119,6 +132,12 @@ func (t *TxManager) WithTx(ctx context.Context, f queryFn) (err error) {
 	return err
 }
 
+// RecordPoolStats adds the connection pool stats of the database to the currently active span, e.g.
+// the transaction span within WithTx, or a query span. See o11y.RecordPoolStats.
+func (t *TxManager) RecordPoolStats(ctx context.Context) {
+	o11y.RecordPoolStats(ctx, t.db.Stats())
+}
+
 func (t *TxManager) NoTx() Querier {
 	return unifiedQuerier{q: eDB{t.db}}
 }
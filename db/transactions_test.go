@@ -6,6 +6,7 @@ import (
 	"database/sql/driver"
 	"errors"
 	"fmt"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -14,7 +15,9 @@ import (
 	"gotest.tools/v3/assert"
 	"gotest.tools/v3/assert/cmp"
 
+	"github.com/circleci/ex/internal/syncbuffer"
 	"github.com/circleci/ex/o11y"
+	"github.com/circleci/ex/o11y/otel"
 )
 
 func TestNoEffectError(t *testing.T) {
@@ -89,6 +92,55 @@ func TestTxManager_WithTx_ContextCancelledWithError(t *testing.T) {
 	}
 }
 
+func TestTxManager_WithTx_Span(t *testing.T) {
+	var b syncbuffer.SyncBuffer
+	op, err := otel.New(otel.Config{Writer: &b})
+	assert.NilError(t, err)
+	ctx := o11y.WithProvider(context.Background(), op)
+
+	tx := NewTxManager(sqlx.NewDb(sql.OpenDB(fakeConnector{tx: &fakeTx{}}), "fake"))
+	err = tx.WithTx(ctx, func(ctx context.Context, _ Querier) error {
+		_, span := Span(ctx, "builds", "insert")
+		span.End()
+		return nil
+	})
+	assert.NilError(t, err)
+
+	ourError := errors.New("our error")
+	err = tx.WithTx(ctx, func(ctx context.Context, _ Querier) error {
+		return ourError
+	})
+	assert.Check(t, errors.Is(err, ourError))
+	op.Close(ctx)
+
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	assert.Assert(t, cmp.Len(lines, 3))
+	// the query span is a child of the transaction span, so shares its trace id
+	assert.Check(t, cmp.Contains(lines[0], "db: builds.insert"))
+	assert.Check(t, cmp.Equal(strings.Fields(lines[0])[1], strings.Fields(lines[1])[1]))
+	assert.Check(t, cmp.Contains(lines[1], "db: transaction db.system=postgresql result=success tx.outcome=committed"))
+	assert.Check(t, cmp.Contains(lines[2],
+		"db: transaction db.system=postgresql error=our error result=error tx.outcome=rolled_back"))
+}
+
+func TestTxManager_RecordPoolStats(t *testing.T) {
+	var b syncbuffer.SyncBuffer
+	op, err := otel.New(otel.Config{Writer: &b})
+	assert.NilError(t, err)
+	ctx := o11y.WithProvider(context.Background(), op)
+
+	tx := NewTxManager(sqlx.NewDb(sql.OpenDB(fakeConnector{tx: &fakeTx{}}), "fake"))
+	err = tx.WithTx(ctx, func(ctx context.Context, _ Querier) error {
+		tx.RecordPoolStats(ctx)
+		return nil
+	})
+	assert.NilError(t, err)
+	op.Close(ctx)
+
+	assert.Check(t, cmp.Contains(b.String(),
+		"db: transaction db.pool.idle=0 db.pool.in_use=1 db.pool.open=1 db.pool.wait_count=0 db.system=postgresql"))
+}
+
 type fakeConnector struct {
 	driver.Connector
 	tx *fakeTx
@@ -0,0 +1,44 @@
+package grpc
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc"
+
+	"github.com/circleci/ex/o11y"
+)
+
+// UnaryServerInterceptor returns a server interceptor that adds the rpc.deadline_ms field, the time
+// remaining until the deadline of the incoming call, to the span in the context. It does not start a
+// span, so it should be chained after whatever starts the server span.
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (interface{}, error) {
+		addDeadlineField(ctx)
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor is the streaming equivalent of UnaryServerInterceptor.
+func StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, _ *grpc.StreamServerInfo,
+		handler grpc.StreamHandler) error {
+		addDeadlineField(ss.Context())
+		return handler(srv, ss)
+	}
+}
+
+// addDeadlineField records the time remaining until the deadline of ctx, if it has one, so that
+// timeouts can be told apart as either a tight deadline or a slow server.
+func addDeadlineField(ctx context.Context) {
+	dl, ok := ctx.Deadline()
+	if !ok {
+		return
+	}
+	span := o11y.FromContext(ctx).GetSpan(ctx)
+	if span == nil {
+		return
+	}
+	span.AddRawField("rpc.deadline_ms", float64(time.Until(dl))/float64(time.Millisecond))
+}
@@ -20,7 +20,14 @@ type Config struct {
 	ServiceName string
 	// Timeout is the maximum duration we allow any call to take. Note if this timeout is
 	// hit then the default retries will not happen, it is up to the caller to decide on retry behaviour.
+	// The time remaining until the deadline of each call is recorded in the rpc.deadline_ms field.
 	Timeout time.Duration
+	// RecordMessageSizes adds the rpc.request.size and rpc.response.size fields, in bytes, to the span
+	// of each call. For streaming calls these are the totals across all messages, and the message
+	// counts are recorded in rpc.request.messages and rpc.response.messages, along with an rpc.message
+	// event with the size of each message. The sizes are taken from the codec, so messages are not
+	// marshalled twice.
+	RecordMessageSizes bool
 }
 
 // Dial wraps up a standard set of dial behaviours that most grpc clients will want to use.
@@ -32,10 +39,15 @@ func Dial(conf Config) (*grpc.ClientConn, error) {
 		grpc.WithDefaultServiceConfig(ServiceConfig(conf.ServiceName)),
 	}
 
+	if conf.RecordMessageSizes {
+		opts = append(opts, grpc.WithStatsHandler(sizeHandler{}))
+	}
+
 	o11yInterceptor := func(ctx context.Context, method string, req, reply interface{},
 		cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
 		o11y.AddField(ctx, "grpc_service", conf.ServiceName)
 		o11y.AddField(ctx, "grpc_method", method)
+		addDeadlineField(ctx)
 		err := invoker(ctx, method, req, reply, cc, opts...)
 		o11y.AddField(ctx, "grpc_error", err)
 		return err
@@ -49,7 +61,8 @@ func Dial(conf Config) (*grpc.ClientConn, error) {
 			defer cancel()
 			return invoker(ctx, method, req, reply, cc, opts...)
 		}
-		opts = append(opts, grpc.WithChainUnaryInterceptor(o11yInterceptor, timeoutInterceptor))
+		// the timeout is applied first, so that the o11y interceptor records the deadline it sets
+		opts = append(opts, grpc.WithChainUnaryInterceptor(timeoutInterceptor, o11yInterceptor))
 	} else {
 		opts = append(opts, grpc.WithUnaryInterceptor(o11yInterceptor))
 	}
@@ -2,8 +2,11 @@ package grpc
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"net"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -15,7 +18,9 @@ import (
 	"gotest.tools/v3/assert/cmp"
 
 	"github.com/circleci/ex/grpc/internal/testgrpc"
+	"github.com/circleci/ex/internal/syncbuffer"
 	"github.com/circleci/ex/o11y"
+	"github.com/circleci/ex/o11y/otel"
 	"github.com/circleci/ex/testing/testcontext"
 )
 
@@ -98,15 +103,166 @@ func TestDial(t *testing.T) {
 	})
 }
 
-func startGRPCServer(ctx context.Context, host string) (srv *pingPongServer, stop func(), err error) {
+func TestDial_RecordMessageSizes(t *testing.T) {
+	srv, cleanup, err := startGRPCServer(testcontext.Background(), "localhost:0")
+	assert.NilError(t, err)
+	t.Cleanup(cleanup)
+
+	var b syncbuffer.SyncBuffer
+	op, err := otel.New(otel.Config{Writer: &b})
+	assert.NilError(t, err)
+	ctx := o11y.WithProvider(context.Background(), op)
+
+	con, err := Dial(Config{
+		Host:               srv.addr,
+		ServiceName:        "testgrpc.PingPong",
+		RecordMessageSizes: true,
+	})
+	assert.NilError(t, err)
+	cl := testgrpc.NewPingPongClient(con)
+
+	ctx, span := o11y.StartSpan(ctx, "call")
+	_, err = cl.Ping(ctx, &testgrpc.PingRequest{Caller: "me"})
+	assert.NilError(t, err)
+	span.End()
+	op.Close(ctx)
+
+	assert.Check(t, cmp.Contains(b.String(), " rpc.request.size=4"))
+	assert.Check(t, cmp.Contains(b.String(), " rpc.response.size=9"))
+	assert.Check(t, !strings.Contains(b.String(), "rpc.request.messages"))
+	assert.Check(t, !strings.Contains(b.String(), "rpc.message "), "unary calls have no message events")
+}
+
+func TestDial_RecordMessageSizes_Streaming(t *testing.T) {
+	srv, cleanup, err := startGRPCServer(testcontext.Background(), "localhost:0")
+	assert.NilError(t, err)
+	t.Cleanup(cleanup)
+
+	var b syncbuffer.SyncBuffer
+	op, err := otel.New(otel.Config{Writer: &b})
+	assert.NilError(t, err)
+	ctx := o11y.WithProvider(context.Background(), op)
+
+	con, err := Dial(Config{
+		Host:               srv.addr,
+		ServiceName:        "testgrpc.PingPong",
+		RecordMessageSizes: true,
+	})
+	assert.NilError(t, err)
+
+	ctx, span := o11y.StartSpan(ctx, "call")
+	stream, err := con.NewStream(ctx, &pingStreamDesc.Streams[0], "/testgrpc.PingStream/Pings")
+	assert.NilError(t, err)
+	for _, caller := range []string{"me", "you"} {
+		assert.NilError(t, stream.SendMsg(&testgrpc.PingRequest{Caller: caller}))
+	}
+	assert.NilError(t, stream.CloseSend())
+	for {
+		var reply testgrpc.PingReply
+		if err := stream.RecvMsg(&reply); err != nil {
+			assert.Check(t, errors.Is(err, io.EOF), err)
+			break
+		}
+	}
+	span.End()
+	op.Close(ctx)
+
+	out := b.String()
+	assert.Check(t, cmp.Contains(out, " rpc.request.size=9"))
+	assert.Check(t, cmp.Contains(out, " rpc.response.size=19"))
+	assert.Check(t, cmp.Contains(out, " rpc.request.messages=2"))
+	assert.Check(t, cmp.Contains(out, " rpc.response.messages=2"))
+
+	for _, event := range []string{
+		"rpc.message app.rpc.message.id=1 app.rpc.message.size=4 app.rpc.message.type=SENT",
+		"rpc.message app.rpc.message.id=2 app.rpc.message.size=5 app.rpc.message.type=SENT",
+		"rpc.message app.rpc.message.id=1 app.rpc.message.size=9 app.rpc.message.type=RECEIVED",
+		"rpc.message app.rpc.message.id=2 app.rpc.message.size=10 app.rpc.message.type=RECEIVED",
+	} {
+		assert.Check(t, cmp.Contains(out, event))
+	}
+}
+
+func TestDial_DeadlineField(t *testing.T) {
+	srv, cleanup, err := startGRPCServer(testcontext.Background(), "localhost:0")
+	assert.NilError(t, err)
+	t.Cleanup(cleanup)
+
+	call := func(t *testing.T, timeout time.Duration) string {
+		var b syncbuffer.SyncBuffer
+		op, err := otel.New(otel.Config{Writer: &b})
+		assert.NilError(t, err)
+		ctx := o11y.WithProvider(context.Background(), op)
+
+		con, err := Dial(Config{
+			Host:        srv.addr,
+			ServiceName: "testgrpc.PingPong",
+			Timeout:     timeout,
+		})
+		assert.NilError(t, err)
+		cl := testgrpc.NewPingPongClient(con)
+
+		ctx, span := o11y.StartSpan(ctx, "call")
+		_, err = cl.Ping(ctx, &testgrpc.PingRequest{Caller: "me"})
+		assert.NilError(t, err)
+		span.End()
+		op.Close(ctx)
+		return b.String()
+	}
+
+	t.Run("with-timeout", func(t *testing.T) {
+		assert.Check(t, cmp.Contains(call(t, time.Minute), " rpc.deadline_ms="))
+	})
+
+	t.Run("no-timeout", func(t *testing.T) {
+		assert.Check(t, !strings.Contains(call(t, 0), "rpc.deadline_ms"))
+	})
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	var b syncbuffer.SyncBuffer
+	op, err := otel.New(otel.Config{Writer: &b})
+	assert.NilError(t, err)
+
+	// stands in for whatever starts the server span
+	spanInterceptor := func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, span := o11y.StartSpan(o11y.WithProvider(ctx, op), "server")
+		defer span.End()
+		return handler(ctx, req)
+	}
+
+	srv, cleanup, err := startGRPCServer(testcontext.Background(), "localhost:0",
+		grpc.ChainUnaryInterceptor(spanInterceptor, UnaryServerInterceptor()))
+	assert.NilError(t, err)
+	t.Cleanup(cleanup)
+
+	con, err := Dial(Config{
+		Host:        srv.addr,
+		ServiceName: "testgrpc.PingPong",
+		Timeout:     time.Minute,
+	})
+	assert.NilError(t, err)
+	cl := testgrpc.NewPingPongClient(con)
+
+	_, err = cl.Ping(testcontext.Background(), &testgrpc.PingRequest{Caller: "me"})
+	assert.NilError(t, err)
+	op.Close(context.Background())
+
+	assert.Check(t, cmp.Contains(b.String(), " rpc.deadline_ms="))
+}
+
+func startGRPCServer(ctx context.Context, host string,
+	opts ...grpc.ServerOption) (srv *pingPongServer, stop func(), err error) {
 	lis, err := net.Listen("tcp", host)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to listen: %w", err)
 	}
 
-	server := grpc.NewServer(grpc.ConnectionTimeout(5 * time.Second))
+	server := grpc.NewServer(append([]grpc.ServerOption{grpc.ConnectionTimeout(5 * time.Second)}, opts...)...)
 	srv = &pingPongServer{}
 	testgrpc.RegisterPingPongServer(server, srv)
+	server.RegisterService(&pingStreamDesc, srv)
 
 	go func() {
 		if err := server.Serve(lis); err != nil {
@@ -147,6 +303,40 @@ func (s *pingPongServer) Ping(ctx context.Context, req *testgrpc.PingRequest) (*
 	return &testgrpc.PingReply{Message: "pong " + req.Caller}, nil
 }
 
+// pingStreamDesc describes a streaming service without generated code, replying to every ping
+// sent on the stream.
+var pingStreamDesc = grpc.ServiceDesc{
+	ServiceName: "testgrpc.PingStream",
+	HandlerType: (*pingStreamer)(nil),
+	Streams: []grpc.StreamDesc{{
+		StreamName: "Pings",
+		Handler: func(srv interface{}, stream grpc.ServerStream) error {
+			return srv.(pingStreamer).Pings(stream)
+		},
+		ServerStreams: true,
+		ClientStreams: true,
+	}},
+}
+
+type pingStreamer interface {
+	Pings(grpc.ServerStream) error
+}
+
+func (s *pingPongServer) Pings(stream grpc.ServerStream) error {
+	for {
+		var req testgrpc.PingRequest
+		if err := stream.RecvMsg(&req); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		if err := stream.SendMsg(&testgrpc.PingReply{Message: "pong " + req.Caller}); err != nil {
+			return err
+		}
+	}
+}
+
 func (s *pingPongServer) callCount() int {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
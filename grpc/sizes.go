@@ -0,0 +1,93 @@
+package grpc
+
+import (
+	"context"
+	"sync"
+
+	"google.golang.org/grpc/stats"
+
+	"github.com/circleci/ex/o11y"
+)
+
+// sizeHandler is a client stats handler that records the sizes of the messages sent and received
+// on the current span. The sizes are as reported by the codec, so the messages are not marshalled
+// a second time to measure them.
+type sizeHandler struct{}
+
+type sizeKey struct{}
+
+type sizes struct {
+	mu            sync.Mutex
+	streaming     bool
+	request       int
+	requestCount  int
+	response      int
+	responseCount int
+}
+
+func (sizeHandler) TagRPC(ctx context.Context, _ *stats.RPCTagInfo) context.Context {
+	return context.WithValue(ctx, sizeKey{}, &sizes{})
+}
+
+func (sizeHandler) HandleRPC(ctx context.Context, rs stats.RPCStats) {
+	sz, ok := ctx.Value(sizeKey{}).(*sizes)
+	if !ok {
+		return
+	}
+
+	switch s := rs.(type) {
+	case *stats.Begin:
+		sz.mu.Lock()
+		sz.streaming = s.IsClientStream || s.IsServerStream
+		sz.mu.Unlock()
+	case *stats.OutPayload:
+		sz.mu.Lock()
+		sz.request += s.Length
+		sz.requestCount++
+		streaming, id := sz.streaming, sz.requestCount
+		sz.mu.Unlock()
+		if streaming {
+			logMessage(ctx, "SENT", id, s.Length)
+		}
+	case *stats.InPayload:
+		sz.mu.Lock()
+		sz.response += s.Length
+		sz.responseCount++
+		streaming, id := sz.streaming, sz.responseCount
+		sz.mu.Unlock()
+		if streaming {
+			logMessage(ctx, "RECEIVED", id, s.Length)
+		}
+	case *stats.End:
+		span := o11y.FromContext(ctx).GetSpan(ctx)
+		if span == nil {
+			return
+		}
+		sz.mu.Lock()
+		defer sz.mu.Unlock()
+		span.AddRawField("rpc.request.size", sz.request)
+		span.AddRawField("rpc.response.size", sz.response)
+		// the sizes of streaming calls are totals over any number of messages
+		if sz.streaming {
+			span.AddRawField("rpc.request.messages", sz.requestCount)
+			span.AddRawField("rpc.response.messages", sz.responseCount)
+		}
+	}
+}
+
+// logMessage sends an rpc.message event for each message of a streaming call, following the otel
+// semantic conventions for the message type (SENT or RECEIVED) and id, which counts from 1 in each
+// direction.
+func logMessage(ctx context.Context, typ string, id, size int) {
+	o11y.Log(ctx, "rpc.message",
+		o11y.Field("rpc.message.type", typ),
+		o11y.Field("rpc.message.id", id),
+		o11y.Field("rpc.message.size", size),
+	)
+}
+
+func (sizeHandler) TagConn(ctx context.Context, _ *stats.ConnTagInfo) context.Context {
+	return ctx
+}
+
+func (sizeHandler) HandleConn(context.Context, stats.ConnStats) {}
@@ -10,6 +10,8 @@ import (
 	"time"
 
 	"github.com/vmihailenco/go-tinylfu"
+
+	"github.com/circleci/ex/o11y"
 )
 
 const (
@@ -46,6 +48,10 @@ type Config struct {
 	// Resolver optionally allows specifying a custom resolver
 	Resolver *net.Resolver
 
+	// SpanFields adds the dns.lookup_ms and dns.cache_hit fields to the active span for each resolution,
+	// e.g. the http client span when used with DialContext, to make latency from DNS visible.
+	SpanFields bool
+
 	lookupFunc func(ctx context.Context, r *net.Resolver, host string) ([]net.IP, error)
 }
 
@@ -75,10 +81,14 @@ func New(c Config) *Resolver {
 func (r *Resolver) Resolve(ctx context.Context, addr string) ([]net.IP, error) {
 	v, ok := r.cacheGet(addr)
 	if ok {
+		r.addSpanFields(ctx, true, 0)
 		return v.([]net.IP), nil
 	}
 
+	// N.B. the lookup is made without holding the lock, so concurrent lookups are not serialized
+	start := time.Now()
 	ips, err := r.config.lookupFunc(ctx, r.config.Resolver, addr)
+	r.addSpanFields(ctx, false, time.Since(start))
 	if err != nil {
 		return nil, err
 	}
@@ -91,6 +101,18 @@ func (r *Resolver) Resolve(ctx context.Context, addr string) ([]net.IP, error) {
 	return ips, nil
 }
 
+func (r *Resolver) addSpanFields(ctx context.Context, hit bool, lookup time.Duration) {
+	if !r.config.SpanFields {
+		return
+	}
+	span := o11y.FromContext(ctx).GetSpan(ctx)
+	if span == nil {
+		return
+	}
+	span.AddRawField("dns.cache_hit", hit)
+	span.AddRawField("dns.lookup_ms", float64(lookup)/float64(time.Millisecond))
+}
+
 func (r *Resolver) cacheSet(item *tinylfu.Item) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
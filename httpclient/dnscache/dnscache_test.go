@@ -10,6 +10,7 @@ import (
 	"gotest.tools/v3/assert"
 	"gotest.tools/v3/assert/cmp"
 
+	"github.com/circleci/ex/o11y"
 	"github.com/circleci/ex/testing/testcontext"
 )
 
@@ -81,3 +82,41 @@ func TestResolver_Resolve_CheckCached(t *testing.T) {
 		assert.Check(t, cmp.Equal(atomic.LoadInt64(&lookupCount), int64(len(hosts))))
 	})
 }
+
+func TestResolver_Resolve_SpanFields(t *testing.T) {
+	lookup := func(ctx context.Context, r *net.Resolver, host string) ([]net.IP, error) {
+		return []net.IP{net.ParseIP("127.0.0.1")}, nil
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		resolver := New(Config{lookupFunc: lookup})
+		ctx, span := o11y.StartSpan(testcontext.Background(), "dial")
+		defer span.End()
+
+		_, err := resolver.Resolve(ctx, "a.example.com")
+		assert.Assert(t, err)
+		_, ok := o11y.FieldsSnapshot(span)["dns.cache_hit"]
+		assert.Check(t, !ok)
+	})
+
+	t.Run("enabled", func(t *testing.T) {
+		resolver := New(Config{lookupFunc: lookup, SpanFields: true})
+
+		ctx, span := o11y.StartSpan(testcontext.Background(), "dial")
+		_, err := resolver.Resolve(ctx, "a.example.com")
+		assert.Assert(t, err)
+		fields := o11y.FieldsSnapshot(span)
+		span.End()
+		assert.Check(t, cmp.Equal(fields["dns.cache_hit"], false))
+		_, ok := fields["dns.lookup_ms"].(float64)
+		assert.Check(t, ok)
+
+		ctx, span = o11y.StartSpan(testcontext.Background(), "dial")
+		_, err = resolver.Resolve(ctx, "a.example.com")
+		assert.Assert(t, err)
+		fields = o11y.FieldsSnapshot(span)
+		span.End()
+		assert.Check(t, cmp.Equal(fields["dns.cache_hit"], true))
+		assert.Check(t, cmp.Equal(fields["dns.lookup_ms"], 0.0))
+	})
+}
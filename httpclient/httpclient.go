@@ -69,6 +69,26 @@ type Config struct {
 	NoRateLimitBackoff bool
 	// DisableW3CTracePropagation is a temporary option to disable sending w3c trace propagation headers
 	DisableW3CTracePropagation bool
+	// PhaseTimings adds the time spent in the DNS lookup, TCP connect, TLS handshake and waiting for the
+	// first response byte to each request span, as http.dns_ms, http.connect_ms, http.tls_ms and
+	// http.ttfb_ms. This is off by default due to the small overhead of the httptrace hooks.
+	PhaseTimings bool
+	// QuotaHeaders names the rate limit response headers of the upstream service, which are recorded
+	// on each request span as upstream.quota_remaining and upstream.quota_reset. The defaults are the
+	// common X-RateLimit-Remaining and X-RateLimit-Reset headers.
+	QuotaHeaders QuotaHeaders
+	// RequestHeaders and ResponseHeaders name the headers to record on each request span, as
+	// http.request.header.* and http.response.header.* fields, see o11y.AddHeaderFields.
+	// No headers are recorded by default.
+	RequestHeaders  []string
+	ResponseHeaders []string
+}
+
+// QuotaHeaders names the headers an upstream service uses to report the remaining rate limit quota,
+// and when the quota resets. Either may be empty to use the default header.
+type QuotaHeaders struct {
+	Remaining string
+	Reset     string
 }
 
 // Client is the o11y instrumented http client.
@@ -83,6 +103,10 @@ type Client struct {
 	additionalHeaders     map[string]string
 	tracer                tracer
 	noRateLimitBackoff    bool
+	phaseTimings          bool
+	quotaHeaders          QuotaHeaders
+	reqHeaders            []string
+	respHeaders           []string
 	// temporary - whilst we cut over to otel and a shared dataset
 	disableW3CTracePropagation bool
 
@@ -135,6 +159,10 @@ func New(cfg Config) *Client {
 		tracer:                     cfg.Tracer,
 		now:                        time.Now,
 		noRateLimitBackoff:         cfg.NoRateLimitBackoff,
+		phaseTimings:               cfg.PhaseTimings,
+		quotaHeaders:               cfg.QuotaHeaders.withDefaults(),
+		reqHeaders:                 cfg.RequestHeaders,
+		respHeaders:                cfg.ResponseHeaders,
 		disableW3CTracePropagation: cfg.DisableW3CTracePropagation,
 	}
 }
@@ -480,6 +508,11 @@ func (c *Client) retryRequest(ctx context.Context, name string, r Request, newRe
 		if c.tracer != nil {
 			ctx = c.tracer.WithTracer(ctx, r.route)
 		}
+		if c.phaseTimings {
+			p := &phases{}
+			ctx = p.withTrace(ctx)
+			defer p.addToSpan(span)
+		}
 
 		req = req.WithContext(ctx)
 		if r.propagation {
@@ -490,6 +523,7 @@ func (c *Client) retryRequest(ctx context.Context, name string, r Request, newRe
 		span.AddRawField("http.route", r.route)
 		span.AddRawField("http.base_url", c.baseURL)
 		addReqToSpan(span, req, attemptCounter)
+		o11y.AddHeaderFields(span, "http.request.header", req.Header, c.reqHeaders)
 
 		res, err := c.httpClient.Do(req)
 		if err != nil {
@@ -523,6 +557,8 @@ func (c *Client) retryRequest(ctx context.Context, name string, r Request, newRe
 			)
 		}
 		addRespToSpan(span, res)
+		c.quotaHeaders.addToSpan(span, res)
+		o11y.AddHeaderFields(span, "http.response.header", res.Header, c.respHeaders)
 
 		err = extractHTTPError(req, res, attemptCounter, r.route)
 		if err != nil {
@@ -625,6 +661,34 @@ func addRespToSpan(span o11y.Span, res *http.Response) {
 	span.AddRawField("http.status_code", res.StatusCode)
 }
 
+func (q QuotaHeaders) withDefaults() QuotaHeaders {
+	if q.Remaining == "" {
+		q.Remaining = "X-RateLimit-Remaining"
+	}
+	if q.Reset == "" {
+		q.Reset = "X-RateLimit-Reset"
+	}
+	return q
+}
+
+// addToSpan records the quota headers that are present in the response. Numeric values are recorded
+// as numbers, otherwise the raw value is recorded, since the reset format differs between vendors.
+func (q QuotaHeaders) addToSpan(span o11y.Span, res *http.Response) {
+	add := func(key, header string) {
+		v := res.Header.Get(header)
+		if v == "" {
+			return
+		}
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+			span.AddRawField(key, n)
+			return
+		}
+		span.AddRawField(key, v)
+	}
+	add("upstream.quota_remaining", q.Remaining)
+	add("upstream.quota_reset", q.Reset)
+}
+
 func (c *Client) shouldBackoff() bool {
 	if c.noRateLimitBackoff {
 		return false
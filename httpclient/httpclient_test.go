@@ -27,6 +27,7 @@ import (
 	"github.com/circleci/ex/httpclient/dnscache"
 	"github.com/circleci/ex/httpserver"
 	"github.com/circleci/ex/httpserver/ginrouter"
+	"github.com/circleci/ex/internal/syncbuffer"
 	"github.com/circleci/ex/o11y"
 	"github.com/circleci/ex/o11y/otel"
 	"github.com/circleci/ex/o11y/wrappers/o11ynethttp"
@@ -1027,3 +1028,117 @@ func TestClient_Call_429(t *testing.T) {
 		})
 	}
 }
+
+func TestClient_PhaseTimings(t *testing.T) {
+	var b syncbuffer.SyncBuffer
+	op, err := otel.New(otel.Config{Writer: &b})
+	assert.NilError(t, err)
+	ctx := o11y.WithProvider(context.Background(), op)
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	t.Cleanup(server.Close)
+
+	client := httpclient.New(httpclient.Config{
+		Name:         "phases",
+		BaseURL:      server.URL,
+		Timeout:      time.Second,
+		Transport:    server.Client().Transport,
+		PhaseTimings: true,
+	})
+	for i := 0; i < 2; i++ {
+		err = client.Call(ctx, httpclient.NewRequest("GET", "/"))
+		assert.NilError(t, err)
+	}
+	op.Close(ctx)
+
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	assert.Assert(t, cmp.Len(lines, 2))
+
+	// the first request makes a new connection
+	assert.Check(t, cmp.Contains(lines[0], "http.conn_reused=false"))
+	assert.Check(t, cmp.Regexp(`http.connect_ms=\d`, lines[0]))
+	assert.Check(t, cmp.Regexp(`http.tls_ms=\d`, lines[0]))
+	assert.Check(t, cmp.Regexp(`http.ttfb_ms=\d`, lines[0]))
+
+	// the second reuses it
+	assert.Check(t, cmp.Contains(lines[1], "http.conn_reused=true"))
+	assert.Check(t, !strings.Contains(lines[1], "http.connect_ms"))
+	assert.Check(t, !strings.Contains(lines[1], "http.tls_ms"))
+	assert.Check(t, cmp.Regexp(`http.ttfb_ms=\d`, lines[1]))
+}
+
+func TestClient_QuotaHeaders(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Remaining", "42")
+		w.Header().Set("X-RateLimit-Reset", "1700000000")
+		w.Header().Set("RateLimit-Remaining", "7")
+		w.Header().Set("RateLimit-Reset", "Wed, 21 Oct 2015 07:28:00 GMT")
+	}))
+	t.Cleanup(server.Close)
+
+	tests := []struct {
+		name    string
+		headers httpclient.QuotaHeaders
+		expect  string
+	}{
+		{
+			name:   "defaults",
+			expect: "upstream.quota_remaining=42 upstream.quota_reset=1700000000",
+		},
+		{
+			name:    "vendor headers",
+			headers: httpclient.QuotaHeaders{Remaining: "RateLimit-Remaining", Reset: "RateLimit-Reset"},
+			expect:  "upstream.quota_remaining=7 upstream.quota_reset=Wed, 21 Oct 2015 07:28:00 GMT",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b syncbuffer.SyncBuffer
+			op, err := otel.New(otel.Config{Writer: &b})
+			assert.NilError(t, err)
+			ctx := o11y.WithProvider(context.Background(), op)
+
+			client := httpclient.New(httpclient.Config{
+				Name:         "quota",
+				BaseURL:      server.URL,
+				Timeout:      time.Second,
+				QuotaHeaders: tt.headers,
+			})
+			err = client.Call(ctx, httpclient.NewRequest("GET", "/"))
+			assert.NilError(t, err)
+			op.Close(ctx)
+
+			assert.Check(t, cmp.Contains(b.String(), tt.expect))
+		})
+	}
+}
+
+func TestClient_Headers(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-store")
+		w.Header().Set("Set-Cookie", "session=secret")
+	}))
+	t.Cleanup(server.Close)
+
+	var b syncbuffer.SyncBuffer
+	op, err := otel.New(otel.Config{Writer: &b})
+	assert.NilError(t, err)
+	ctx := o11y.WithProvider(context.Background(), op)
+
+	client := httpclient.New(httpclient.Config{
+		Name:            "headers",
+		BaseURL:         server.URL,
+		Timeout:         time.Second,
+		AuthToken:       "secret",
+		RequestHeaders:  []string{"User-Agent"},
+		ResponseHeaders: []string{"Cache-Control"},
+	})
+	err = client.Call(ctx, httpclient.NewRequest("GET", "/"))
+	assert.NilError(t, err)
+	op.Close(ctx)
+
+	out := b.String()
+	assert.Check(t, cmp.Contains(out, "http.request.header.user_agent=CircleCI (headers, ex) "))
+	assert.Check(t, cmp.Contains(out, "http.response.header.cache_control=no-store "))
+	assert.Check(t, !strings.Contains(out, "secret"))
+}
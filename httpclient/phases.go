@@ -0,0 +1,90 @@
+package httpclient
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http/httptrace"
+	"sync"
+	"time"
+
+	"github.com/circleci/ex/o11y"
+)
+
+// phases records the sub timings of a single request attempt from the httptrace hooks.
+// The hooks may be called concurrently, e.g. dialing multiple addresses, hence the lock.
+type phases struct {
+	mu sync.Mutex
+
+	start      time.Time
+	dnsStart   time.Time
+	dns        time.Duration
+	dialStart  time.Time
+	connect    time.Duration
+	tlsStart   time.Time
+	tls        time.Duration
+	firstByte  time.Duration
+	connReused bool
+}
+
+func (p *phases) withTrace(ctx context.Context) context.Context {
+	p.start = time.Now()
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			p.locked(func() { p.connReused = info.Reused })
+		},
+		DNSStart: func(httptrace.DNSStartInfo) {
+			p.locked(func() { p.dnsStart = time.Now() })
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			p.locked(func() { p.dns = time.Since(p.dnsStart) })
+		},
+		ConnectStart: func(_, _ string) {
+			p.locked(func() {
+				if p.dialStart.IsZero() {
+					p.dialStart = time.Now()
+				}
+			})
+		},
+		ConnectDone: func(_, _ string, err error) {
+			if err != nil {
+				return
+			}
+			p.locked(func() { p.connect = time.Since(p.dialStart) })
+		},
+		TLSHandshakeStart: func() {
+			p.locked(func() { p.tlsStart = time.Now() })
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			p.locked(func() { p.tls = time.Since(p.tlsStart) })
+		},
+		GotFirstResponseByte: func() {
+			p.locked(func() { p.firstByte = time.Since(p.start) })
+		},
+	})
+}
+
+func (p *phases) locked(f func()) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	f()
+}
+
+// addToSpan adds the phases that happened to the span. Phases that did not happen, e.g. dns and
+// connect on a reused connection, are omitted.
+func (p *phases) addToSpan(span o11y.Span) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	span.AddRawField("http.conn_reused", p.connReused)
+	addPhase(span, "http.dns_ms", p.dns)
+	addPhase(span, "http.connect_ms", p.connect)
+	addPhase(span, "http.tls_ms", p.tls)
+	addPhase(span, "http.ttfb_ms", p.firstByte)
+}
+
+func addPhase(span o11y.Span, key string, d time.Duration) {
+	if d == 0 {
+		return
+	}
+	span.AddRawField(key, float64(d.Nanoseconds())/1000000.0)
+}
@@ -0,0 +1,37 @@
+package o11y
+
+import "context"
+
+// ActorType is the kind of actor a request is attributed to
+type ActorType string
+
+const (
+	ActorUser    ActorType = "user"
+	ActorService ActorType = "service"
+	ActorBot     ActorType = "bot"
+)
+
+// Actor identifies who or what made a request, see SetActor.
+type Actor struct {
+	ID    string
+	Type  ActorType
+	OrgID string
+}
+
+// SetActor attributes the current trace to the actor, adding the standard actor.id, actor.type and
+// actor.org_id fields to the trace (see AddFieldToTrace). Empty values are not recorded. Use this in
+// preference to ad-hoc fields such as user-id, so that requests are attributed consistently.
+//
+// The ID is recorded as a plain string field, so it is scrubbed by any redaction the provider
+// applies to field values, e.g. the otel provider RedactPatterns.
+func SetActor(ctx context.Context, actor Actor) {
+	if actor.ID != "" {
+		AddFieldToTrace(ctx, "actor.id", actor.ID)
+	}
+	if actor.Type != "" {
+		AddFieldToTrace(ctx, "actor.type", string(actor.Type))
+	}
+	if actor.OrgID != "" {
+		AddFieldToTrace(ctx, "actor.org_id", actor.OrgID)
+	}
+}
@@ -0,0 +1,49 @@
+package o11y
+
+import (
+	"context"
+	"os"
+	"runtime/metrics"
+	"strings"
+)
+
+// DebugAllocsEnv is the environment variable that enables TrackAllocs, when set to any non-empty
+// value. It is read once, at startup.
+const DebugAllocsEnv = "O11Y_DEBUG_ALLOCS"
+
+var trackAllocs = os.Getenv(DebugAllocsEnv) != ""
+
+// TrackAllocs records the heap allocations so far, and returns a func to defer at the end of the named
+// operation. That adds the number of allocations and bytes allocated since then to the currently active
+// span as the allocs.<name>.count and allocs.<name>.bytes fields, to pinpoint allocation heavy code paths
+// e.g. on canaries.
+//
+// The counts are process wide, so they include allocations by any other goroutines running at the same
+// time. Reading them has a cost on every call, so TrackAllocs does nothing unless enabled by the
+// DebugAllocsEnv environment variable. Dashes in the name are replaced with underscores.
+func TrackAllocs(ctx context.Context, name string) func() {
+	if !trackAllocs {
+		return func() {}
+	}
+	prefix := "allocs." + strings.ReplaceAll(name, "-", "_")
+	startCount, startBytes := readAllocs()
+	return func() {
+		count, bytes := readAllocs()
+		span := FromContext(ctx).GetSpan(ctx)
+		if span == nil {
+			return
+		}
+		span.AddRawField(prefix+".count", count-startCount)
+		span.AddRawField(prefix+".bytes", bytes-startBytes)
+	}
+}
+
+// readAllocs returns the cumulative heap allocations, without the stop the world of runtime.ReadMemStats
+func readAllocs() (count, bytes uint64) {
+	samples := []metrics.Sample{
+		{Name: "/gc/heap/allocs:objects"},
+		{Name: "/gc/heap/allocs:bytes"},
+	}
+	metrics.Read(samples)
+	return samples[0].Value.Uint64(), samples[1].Value.Uint64()
+}
@@ -0,0 +1,47 @@
+package o11y
+
+import (
+	"context"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+var allocSink [][]byte
+
+func TestTrackAllocs(t *testing.T) {
+	allocate := func() {
+		for i := 0; i < 100; i++ {
+			allocSink = append(allocSink, make([]byte, 1024))
+		}
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		ctx := WithProvider(context.Background(), &fakeProvider{})
+		ctx, span := StartSpan(ctx, "op")
+
+		done := TrackAllocs(ctx, "op")
+		allocate()
+		done()
+		assert.Check(t, cmp.Len(span.(*fakeSpan).fields, 0))
+	})
+
+	trackAllocs = true
+	t.Cleanup(func() { trackAllocs = false; allocSink = nil })
+
+	t.Run("enabled", func(t *testing.T) {
+		ctx := WithProvider(context.Background(), &fakeProvider{})
+		ctx, span := StartSpan(ctx, "op")
+
+		done := TrackAllocs(ctx, "alloc-heavy")
+		allocate()
+		done()
+		count, ok := span.(*fakeSpan).fields["allocs.alloc_heavy.count"].(uint64)
+		assert.Assert(t, ok)
+		assert.Check(t, count >= 100, count)
+		bytes, ok := span.(*fakeSpan).fields["allocs.alloc_heavy.bytes"].(uint64)
+		assert.Assert(t, ok)
+		assert.Check(t, bytes >= 100*1024, bytes)
+	})
+}
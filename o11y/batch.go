@@ -0,0 +1,50 @@
+package o11y
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// RecordBatchResult adds the standard batch.total, batch.succeeded and batch.failed fields for a batch
+// operation to the currently active span. If some, but not all, of the items failed it returns a partial
+// error, which AddResultToSpan, and so End, record as ResultPartial, so partial failures are not hidden
+// by a single success or error result. Otherwise, it returns nil. The partial error is a warning (see
+// IsWarning), and IsPartial can be used to tell it apart from other errors. Typically:
+//
+//	defer o11y.End(span, &err)
+//	...
+//	return o11y.RecordBatchResult(ctx, total, succeeded, failed)
+func RecordBatchResult(ctx context.Context, total, succeeded, failed int) error {
+	if span := FromContext(ctx).GetSpan(ctx); span != nil {
+		span.AddRawField("batch.total", total)
+		span.AddRawField("batch.succeeded", succeeded)
+		span.AddRawField("batch.failed", failed)
+	}
+	if failed > 0 && succeeded > 0 {
+		return &partialError{msg: fmt.Sprintf("%d of %d batch items failed", failed, total)}
+	}
+	return nil
+}
+
+// IsPartial returns true if err is, or wraps, a partial error returned by RecordBatchResult.
+func IsPartial(err error) bool {
+	return errors.Is(err, errPartial)
+}
+
+// sentinel partial error to use with errors.Is in IsPartial
+var errPartial = errors.New("partial")
+
+type partialError struct {
+	msg string
+}
+
+func (e *partialError) Error() string {
+	return e.msg
+}
+
+// Is makes a partial error match both the partial and warning sentinels.
+func (e *partialError) Is(target error) bool {
+	// nolint: errorlint // This is intentionally not unwrapping, the targets are the sentinels
+	return target == errPartial || target == errWarning
+}
@@ -0,0 +1,58 @@
+package o11y
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+func TestRecordBatchResult(t *testing.T) {
+	t.Run("without provider", func(t *testing.T) {
+		err := RecordBatchResult(context.Background(), 10, 5, 5)
+		assert.Check(t, IsPartial(err))
+	})
+
+	tests := []struct {
+		name      string
+		succeeded int
+		failed    int
+		err       error
+		result    interface{}
+	}{
+		{name: "all succeeded", succeeded: 10, result: "success"},
+		{name: "partial", succeeded: 7, failed: 3, result: "partial"},
+		{name: "all failed", failed: 10, err: errors.New("all failed"), result: "error"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := WithProvider(context.Background(), &fakeProvider{})
+
+			var span Span
+			bulk := func(ctx context.Context) (err error) {
+				ctx, span = StartSpan(ctx, "bulk")
+				defer End(span, &err)
+
+				if err := RecordBatchResult(ctx, tt.succeeded+tt.failed, tt.succeeded, tt.failed); err != nil {
+					return err
+				}
+				return tt.err
+			}
+			err := bulk(ctx)
+
+			fields := span.(*fakeSpan).fields
+			assert.Check(t, cmp.Equal(fields["batch.total"], 10))
+			assert.Check(t, cmp.Equal(fields["batch.succeeded"], tt.succeeded))
+			assert.Check(t, cmp.Equal(fields["batch.failed"], tt.failed))
+			assert.Check(t, cmp.Equal(fields["result"], tt.result))
+			if tt.result == "partial" {
+				assert.Check(t, IsPartial(err))
+				assert.Check(t, IsWarning(err))
+				assert.Check(t, cmp.Equal(fields["warning"], "3 of 10 batch items failed"))
+				assert.Check(t, cmp.Equal(span.(*fakeSpan).status, StatusOK))
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package o11y
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type budgetKey struct{}
+
+// budget is a logical time budget, that is spent by the spans started within it as they end.
+type budget struct {
+	mu        sync.Mutex
+	remaining time.Duration
+	// parent is any budget this budget was allocated from, which it also spends from
+	parent *budget
+}
+
+func (b *budget) spend(d time.Duration) {
+	for ; b != nil; b = b.parent {
+		b.mu.Lock()
+		b.remaining -= d
+		b.mu.Unlock()
+	}
+}
+
+func (b *budget) left() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.remaining
+}
+
+type budgetSpanKey struct{}
+
+// budgetSpan tracks the time spent by the budgeted children of a span, so that the span only spends the
+// time not already spent by its children, and nested spans do not spend the same time twice.
+type budgetSpan struct {
+	mu         sync.Mutex
+	childSpent time.Duration
+}
+
+// WithBudget returns a child context with a logical time budget of total for the operation, and all
+// of the nested operations started from it. The budget is spent by the spans started from the returned
+// context with StartSpan as they end. Each span records the budget remaining as it starts and ends in
+// the budget.remaining_ms and budget.remaining_at_end_ms fields, and the time it consumed in the
+// budget.consumed_ms field. Time spent in nested spans is only spent from the budget once.
+//
+// Unlike a context deadline, running out of budget does not cancel anything. Instead, operations can
+// query RemainingBudget, e.g. to skip optional downstream calls when time is short. A nested budget
+// is limited to what remains of any budget in ctx, and is also spent from it.
+//
+// N.B. the budget is only spent by providers that support WithOnEnd.
+func WithBudget(ctx context.Context, total time.Duration) context.Context {
+	parent, _ := ctx.Value(budgetKey{}).(*budget)
+	if parent != nil {
+		total = min(total, parent.left())
+	}
+	return context.WithValue(ctx, budgetKey{}, &budget{remaining: total, parent: parent})
+}
+
+// RemainingBudget returns the budget remaining in ctx, which is negative once the budget is overrun.
+// It returns false if there is no budget in ctx.
+func RemainingBudget(ctx context.Context) (time.Duration, bool) {
+	b, ok := ctx.Value(budgetKey{}).(*budget)
+	if !ok {
+		return 0, false
+	}
+	return b.left(), true
+}
+
+// addBudgetField adds the budget remaining in ctx to the span, if there is one.
+func addBudgetField(ctx context.Context, span Span) {
+	if remaining, ok := RemainingBudget(ctx); ok {
+		span.AddRawField("budget.remaining_ms", float64(remaining)/float64(time.Millisecond))
+	}
+}
+
+// budgetSpanOpts returns the options for a span started from ctx to spend from any budget in ctx,
+// along with the context the span should be started from.
+func budgetSpanOpts(ctx context.Context) (context.Context, []SpanOpt) {
+	b, ok := ctx.Value(budgetKey{}).(*budget)
+	if !ok {
+		return ctx, nil
+	}
+	parent, _ := ctx.Value(budgetSpanKey{}).(*budgetSpan)
+	bs := &budgetSpan{}
+	start := time.Now()
+
+	return context.WithValue(ctx, budgetSpanKey{}, bs), []SpanOpt{WithOnEnd(func(span Span) {
+		consumed := time.Since(start)
+		bs.mu.Lock()
+		b.spend(consumed - bs.childSpent)
+		bs.mu.Unlock()
+		if parent != nil {
+			parent.mu.Lock()
+			parent.childSpent += consumed
+			parent.mu.Unlock()
+		}
+
+		span.AddRawField("budget.consumed_ms", float64(consumed)/float64(time.Millisecond))
+		span.AddRawField("budget.remaining_at_end_ms", float64(b.left())/float64(time.Millisecond))
+	})}
+}
@@ -0,0 +1,107 @@
+package o11y
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+func TestBudget(t *testing.T) {
+	approx := func(a, b float64) cmp.Comparison {
+		return func() cmp.Result {
+			if math.Abs(a-b) > 1e-6 {
+				return cmp.ResultFailure(fmt.Sprintf("%v is not %v", a, b))
+			}
+			return cmp.ResultSuccess
+		}
+	}
+	ms := func(t *testing.T, span Span, key string) float64 {
+		t.Helper()
+		v, ok := span.(*fakeSpan).fields[key].(float64)
+		assert.Assert(t, ok, key)
+		return v
+	}
+
+	t.Run("no budget", func(t *testing.T) {
+		ctx := WithProvider(context.Background(), &fakeProvider{})
+		_, ok := RemainingBudget(ctx)
+		assert.Check(t, !ok)
+
+		_, span := StartSpan(ctx, "op")
+		span.End()
+		assert.Check(t, cmp.Len(span.(*fakeSpan).fields, 0))
+	})
+
+	t.Run("recorded on spans", func(t *testing.T) {
+		ctx := WithProvider(context.Background(), &fakeProvider{})
+		ctx = WithBudget(ctx, time.Second)
+
+		remaining, ok := RemainingBudget(ctx)
+		assert.Check(t, ok)
+		assert.Check(t, cmp.Equal(remaining, time.Second))
+
+		_, span := StartSpan(ctx, "op")
+		assert.Check(t, cmp.Equal(ms(t, span, "budget.remaining_ms"), 1000.0))
+		time.Sleep(10 * time.Millisecond)
+		span.End()
+
+		consumed := ms(t, span, "budget.consumed_ms")
+		assert.Check(t, consumed >= 10, consumed)
+		assert.Check(t, approx(ms(t, span, "budget.remaining_at_end_ms"), 1000-consumed))
+
+		remaining, _ = RemainingBudget(ctx)
+		assert.Check(t, approx(float64(remaining)/float64(time.Millisecond), 1000-consumed))
+	})
+
+	t.Run("nested spans use up the budget once", func(t *testing.T) {
+		ctx := WithProvider(context.Background(), &fakeProvider{})
+		ctx = WithBudget(ctx, time.Second)
+
+		parentCtx, parent := StartSpan(ctx, "parent")
+		var children []Span
+		for i := 0; i < 2; i++ {
+			_, child := StartSpan(parentCtx, "child")
+			time.Sleep(10 * time.Millisecond)
+			child.End()
+			children = append(children, child)
+
+			// each child spends from the budget as it ends
+			remaining, _ := RemainingBudget(ctx)
+			assert.Check(t, approx(ms(t, child, "budget.remaining_at_end_ms"),
+				float64(remaining)/float64(time.Millisecond)))
+		}
+		assert.Check(t, ms(t, children[1], "budget.remaining_ms") <= 990, "the second child starts with less")
+		parent.End()
+
+		// the parent consumed the time of its children too, but it is only spent once
+		remaining, _ := RemainingBudget(ctx)
+		consumed := ms(t, parent, "budget.consumed_ms")
+		assert.Check(t, consumed >= 20, consumed)
+		assert.Check(t, approx(float64(remaining)/float64(time.Millisecond), 1000-consumed))
+	})
+
+	t.Run("nested budget is limited by and spends from the parent", func(t *testing.T) {
+		ctx := WithProvider(context.Background(), &fakeProvider{})
+		ctx = WithBudget(ctx, 100*time.Millisecond)
+		remaining, _ := RemainingBudget(WithBudget(ctx, time.Hour))
+		assert.Check(t, cmp.Equal(remaining, 100*time.Millisecond))
+
+		nested := WithBudget(ctx, 10*time.Millisecond)
+		remaining, _ = RemainingBudget(nested)
+		assert.Check(t, cmp.Equal(remaining, 10*time.Millisecond))
+
+		_, span := StartSpan(nested, "op")
+		time.Sleep(20 * time.Millisecond)
+		span.End()
+
+		remaining, _ = RemainingBudget(nested)
+		assert.Check(t, remaining < 0, "the nested budget is overrun: %v", remaining)
+		remaining, _ = RemainingBudget(ctx)
+		assert.Check(t, remaining <= 80*time.Millisecond, remaining)
+	})
+}
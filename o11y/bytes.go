@@ -0,0 +1,42 @@
+package o11y
+
+import (
+	"context"
+)
+
+// RecordBytesRead adds n to the io.bytes_read field of the active span, so that the field holds the
+// total bytes read across multiple calls, e.g. in a streaming handler. It is safe to call concurrently
+// for providers that support accumulating fields, see AddToField.
+func RecordBytesRead(ctx context.Context, n int64) {
+	addToField(ctx, "io.bytes_read", n)
+}
+
+// RecordBytesWritten adds n to the io.bytes_written field of the active span, in the same way as RecordBytesRead.
+func RecordBytesWritten(ctx context.Context, n int64) {
+	addToField(ctx, "io.bytes_written", n)
+}
+
+func addToField(ctx context.Context, key string, n int64) {
+	span := FromContext(ctx).GetSpan(ctx)
+	if span == nil {
+		return
+	}
+	AddToField(span, key, n)
+}
+
+// AddToField adds delta to the integer valued field on the span, which starts from zero. Providers that
+// support it accumulate the field atomically, otherwise it is read back with GetField and then updated,
+// which is not safe for concurrent use. The key is as it appears on the span (see GetField).
+func AddToField(span Span, key string, delta int64) {
+	if a, ok := span.(fieldAccumulator); ok {
+		a.AddToField(key, delta)
+		return
+	}
+	cur, _ := GetField(span, key)
+	n, _ := cur.(int64)
+	span.AddRawField(key, n+delta)
+}
+
+type fieldAccumulator interface {
+	AddToField(key string, delta int64)
+}
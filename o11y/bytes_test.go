@@ -0,0 +1,29 @@
+package o11y
+
+import (
+	"context"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+func TestRecordBytes(t *testing.T) {
+	t.Run("without provider", func(t *testing.T) {
+		RecordBytesRead(context.Background(), 10)
+		RecordBytesWritten(context.Background(), 10)
+	})
+
+	t.Run("accumulates", func(t *testing.T) {
+		ctx := WithProvider(context.Background(), &fakeProvider{})
+		ctx, span := StartSpan(ctx, "stream")
+
+		RecordBytesRead(ctx, 10)
+		RecordBytesRead(ctx, 5)
+		RecordBytesWritten(ctx, 7)
+
+		fields := span.(*fakeSpan).fields
+		assert.Check(t, cmp.Equal(fields["io.bytes_read"], int64(15)))
+		assert.Check(t, cmp.Equal(fields["io.bytes_written"], int64(7)))
+	})
+}
@@ -0,0 +1,45 @@
+package o11y
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// RecordCacheResult adds the standard cache.hit and cache.key fields to the currently active span,
+// and counts the hit or miss against the trace. Providers that support trace counters add the
+// cache.hits and cache.misses totals to the root span. The key is omitted if it is empty.
+//
+// Keys that are high cardinality, or may contain sensitive data, should be hashed with HashValue e.g.
+// o11y.RecordCacheResult(ctx, hit, o11y.HashValue(key))
+func RecordCacheResult(ctx context.Context, hit bool, key string) {
+	span := FromContext(ctx).GetSpan(ctx)
+	if span == nil {
+		return
+	}
+	span.AddRawField("cache.hit", hit)
+	if key != "" {
+		span.AddRawField("cache.key", key)
+	}
+
+	c, ok := span.(traceCounter)
+	if !ok {
+		return
+	}
+	if hit {
+		c.IncrTraceCounter("cache.hits")
+	} else {
+		c.IncrTraceCounter("cache.misses")
+	}
+}
+
+type traceCounter interface {
+	IncrTraceCounter(name string)
+}
+
+// HashValue returns a short stable hash of s, for use as a field value where the raw value is high
+// cardinality or sensitive, but equal values should still be identifiable.
+func HashValue(s string) string {
+	h := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(h[:8])
+}
@@ -0,0 +1,32 @@
+package o11y
+
+import (
+	"context"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+func TestRecordCacheResult(t *testing.T) {
+	t.Run("no span", func(t *testing.T) {
+		RecordCacheResult(context.Background(), true, "key")
+	})
+
+	t.Run("fields", func(t *testing.T) {
+		ctx := WithProvider(context.Background(), &fakeProvider{})
+		ctx, span := StartSpan(ctx, "lookup")
+
+		RecordCacheResult(ctx, false, HashValue("user:1234"))
+
+		fields := span.(*fakeSpan).fields
+		assert.Check(t, cmp.Equal(fields["cache.hit"], false))
+		assert.Check(t, cmp.Equal(fields["cache.key"], "a511c7477309c83d"))
+	})
+}
+
+func TestHashValue(t *testing.T) {
+	assert.Check(t, cmp.Len(HashValue("a"), 16))
+	assert.Check(t, cmp.Equal(HashValue("a"), HashValue("a")))
+	assert.Check(t, HashValue("a") != HashValue("b"))
+}
@@ -0,0 +1,41 @@
+package o11y
+
+import (
+	"context"
+	"sync"
+)
+
+// Standard circuit breaker states for RecordCircuitBreaker.
+const (
+	CircuitClosed   = "closed"
+	CircuitOpen     = "open"
+	CircuitHalfOpen = "half-open"
+)
+
+// circuitStates holds the last recorded state of each named breaker, to detect transitions.
+var circuitStates sync.Map
+
+// RecordCircuitBreaker adds the standard circuit.name and circuit.state fields to the currently
+// active span, so that a breaker tripping can be correlated with a degraded dependency, whichever
+// breaker library is in use. The state is typically one of CircuitClosed, CircuitOpen or
+// CircuitHalfOpen.
+//
+// When the state differs from the one last recorded for the same name in this process, a
+// circuit_breaker_transition event is also sent with circuit.name, circuit.from and circuit.to.
+func RecordCircuitBreaker(ctx context.Context, name, state string) {
+	prev, loaded := circuitStates.Swap(name, state)
+	if loaded && prev.(string) != state {
+		Log(ctx, "circuit_breaker_transition",
+			Field("circuit.name", name),
+			Field("circuit.from", prev),
+			Field("circuit.to", state),
+		)
+	}
+
+	span := FromContext(ctx).GetSpan(ctx)
+	if span == nil {
+		return
+	}
+	span.AddRawField("circuit.name", name)
+	span.AddRawField("circuit.state", state)
+}
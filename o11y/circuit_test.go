@@ -0,0 +1,49 @@
+package o11y
+
+import (
+	"context"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+type logRecordingProvider struct {
+	fakeProvider
+	logs []string
+}
+
+func (p *logRecordingProvider) Log(_ context.Context, name string, fields ...Pair) {
+	for _, f := range fields {
+		name += " " + f.Key + "=" + f.Value.(string)
+	}
+	p.logs = append(p.logs, name)
+}
+
+func TestRecordCircuitBreaker(t *testing.T) {
+	t.Run("without provider", func(t *testing.T) {
+		RecordCircuitBreaker(context.Background(), "noop-breaker", CircuitOpen)
+	})
+
+	t.Run("adds fields and transition events", func(t *testing.T) {
+		p := &logRecordingProvider{}
+		ctx := WithProvider(context.Background(), p)
+		ctx, span := StartSpan(ctx, "request")
+
+		RecordCircuitBreaker(ctx, "test-breaker", CircuitClosed)
+		assert.Check(t, cmp.DeepEqual(span.(*fakeSpan).fields, map[string]interface{}{
+			"circuit.name":  "test-breaker",
+			"circuit.state": "closed",
+		}))
+		assert.Check(t, cmp.Len(p.logs, 0))
+
+		RecordCircuitBreaker(ctx, "test-breaker", CircuitClosed)
+		RecordCircuitBreaker(ctx, "test-breaker", CircuitOpen)
+		RecordCircuitBreaker(ctx, "test-breaker", CircuitHalfOpen)
+		assert.Check(t, cmp.Equal(span.(*fakeSpan).fields["circuit.state"], "half-open"))
+		assert.Check(t, cmp.DeepEqual(p.logs, []string{
+			"circuit_breaker_transition circuit.name=test-breaker circuit.from=closed circuit.to=open",
+			"circuit_breaker_transition circuit.name=test-breaker circuit.from=open circuit.to=half-open",
+		}))
+	})
+}
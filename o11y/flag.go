@@ -0,0 +1,37 @@
+package o11y
+
+import (
+	"context"
+	"strings"
+)
+
+// RecordFlag records a feature flag evaluation on the currently active span. The flag name is added
+// to the flags list field, and the reason (if not empty) as the flag.<name>.reason field. To keep the
+// cardinality of fields in check, the value is only recorded, as the flag.<name> field, if it is a bool.
+// Use RecordFlagValue to record other values. Dashes in the flag name are replaced with underscores.
+func RecordFlag(ctx context.Context, flag string, value interface{}, reason string) {
+	_, isBool := value.(bool)
+	recordFlag(ctx, flag, value, reason, isBool)
+}
+
+// RecordFlagValue is the same as RecordFlag, except that the value is always recorded. It should
+// only be used for flags with a small number of possible values (variants).
+func RecordFlagValue(ctx context.Context, flag string, value interface{}, reason string) {
+	recordFlag(ctx, flag, value, reason, true)
+}
+
+func recordFlag(ctx context.Context, flag string, value interface{}, reason string, withValue bool) {
+	span := FromContext(ctx).GetSpan(ctx)
+	if span == nil {
+		return
+	}
+	flag = strings.ReplaceAll(flag, "-", "_")
+
+	span.AppendField("flags", flag)
+	if withValue {
+		span.AddRawField("flag."+flag, value)
+	}
+	if reason != "" {
+		span.AddRawField("flag."+flag+".reason", reason)
+	}
+}
@@ -0,0 +1,31 @@
+package o11y
+
+import (
+	"context"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+func TestRecordFlag(t *testing.T) {
+	t.Run("no span", func(t *testing.T) {
+		RecordFlag(context.Background(), "flag", true, "")
+	})
+
+	ctx := WithProvider(context.Background(), &fakeProvider{})
+	ctx, span := StartSpan(ctx, "request")
+
+	RecordFlag(ctx, "new-checkout", true, "targeted")
+	RecordFlag(ctx, "theme", "dark", "")
+	RecordFlagValue(ctx, "variant", "b", "rollout")
+
+	fields := span.(*fakeSpan).fields
+	assert.Check(t, cmp.DeepEqual(fields, map[string]interface{}{
+		"app.flags":                []interface{}{"new_checkout", "theme", "variant"},
+		"flag.new_checkout":        true,
+		"flag.new_checkout.reason": "targeted",
+		"flag.variant":             "b",
+		"flag.variant.reason":      "rollout",
+	}))
+}
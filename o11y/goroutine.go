@@ -0,0 +1,43 @@
+package o11y
+
+import (
+	"context"
+	"os"
+	"runtime"
+	"strings"
+)
+
+// DebugGoroutinesEnv is the environment variable that enables TrackGoroutine, when set to any non-empty
+// value. It is read once, at startup.
+const DebugGoroutinesEnv = "O11Y_DEBUG_GOROUTINES"
+
+// goroutineLeakThreshold is the increase in goroutines over a tracked operation that is recorded
+const goroutineLeakThreshold = 5
+
+var trackGoroutines = os.Getenv(DebugGoroutinesEnv) != ""
+
+// TrackGoroutine records the number of goroutines now, and returns a func to defer at the end of the
+// named operation. If the number of goroutines has increased significantly by then, the increase is
+// added to the currently active span as the goroutines.<name>.delta field. This is a heuristic to catch
+// goroutine leaks in specific operations during testing or on canaries, e.g. as a CI regression check.
+//
+// Counting goroutines has a cost on every call, so TrackGoroutine does nothing unless enabled by the
+// DebugGoroutinesEnv environment variable. Dashes in the name are replaced with underscores.
+func TrackGoroutine(ctx context.Context, name string) func() {
+	if !trackGoroutines {
+		return func() {}
+	}
+	key := "goroutines." + strings.ReplaceAll(name, "-", "_") + ".delta"
+	start := runtime.NumGoroutine()
+	return func() {
+		delta := runtime.NumGoroutine() - start
+		if delta < goroutineLeakThreshold {
+			return
+		}
+		span := FromContext(ctx).GetSpan(ctx)
+		if span == nil {
+			return
+		}
+		span.AddRawField(key, delta)
+	}
+}
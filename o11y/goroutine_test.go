@@ -0,0 +1,53 @@
+package o11y
+
+import (
+	"context"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+func TestTrackGoroutine(t *testing.T) {
+	leak := func(n int) func() {
+		stop := make(chan struct{})
+		for i := 0; i < n; i++ {
+			go func() { <-stop }()
+		}
+		return func() { close(stop) }
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		ctx := WithProvider(context.Background(), &fakeProvider{})
+		ctx, span := StartSpan(ctx, "op")
+
+		done := TrackGoroutine(ctx, "op")
+		defer leak(10)()
+		done()
+		assert.Check(t, cmp.Len(span.(*fakeSpan).fields, 0))
+	})
+
+	trackGoroutines = true
+	t.Cleanup(func() { trackGoroutines = false })
+
+	t.Run("no leak", func(t *testing.T) {
+		ctx := WithProvider(context.Background(), &fakeProvider{})
+		ctx, span := StartSpan(ctx, "op")
+
+		done := TrackGoroutine(ctx, "op")
+		done()
+		assert.Check(t, cmp.Len(span.(*fakeSpan).fields, 0))
+	})
+
+	t.Run("leak", func(t *testing.T) {
+		ctx := WithProvider(context.Background(), &fakeProvider{})
+		ctx, span := StartSpan(ctx, "op")
+
+		done := TrackGoroutine(ctx, "leaky-op")
+		defer leak(10)()
+		done()
+		delta, ok := span.(*fakeSpan).fields["goroutines.leaky_op.delta"].(int)
+		assert.Assert(t, ok)
+		assert.Check(t, delta >= 10, delta)
+	})
+}
@@ -0,0 +1,25 @@
+package o11y
+
+import (
+	"net/http"
+	"strings"
+)
+
+// AddHeaderFields adds the values of the named headers to the span, as prefix.name fields, where the
+// name is lower-cased with dashes replaced by underscores, e.g. a prefix of http.request.header and
+// the X-Request-ID header gives http.request.header.x_request_id. Headers that are not present are
+// skipped, and multiple values are joined with ", ".
+//
+// Only explicitly named headers are recorded, so that authorization and other sensitive headers are
+// not captured by accident. The values are still passed through any redaction the provider applies
+// to field values, e.g. the otel provider RedactPatterns.
+func AddHeaderFields(span Span, prefix string, header http.Header, names []string) {
+	for _, name := range names {
+		values := header.Values(name)
+		if len(values) == 0 {
+			continue
+		}
+		key := prefix + "." + strings.ReplaceAll(strings.ToLower(name), "-", "_")
+		span.AddRawField(key, strings.Join(values, ", "))
+	}
+}
@@ -0,0 +1,24 @@
+package o11y
+
+import (
+	"net/http"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+func TestAddHeaderFields(t *testing.T) {
+	span := newFakeSpan()
+	header := http.Header{}
+	header.Set("X-Request-ID", "abc")
+	header.Add("Cache-Control", "no-cache")
+	header.Add("Cache-Control", "no-store")
+	header.Set("Authorization", "Bearer secret")
+
+	AddHeaderFields(span, "http.request.header", header, []string{"x-request-id", "Cache-Control", "X-Missing"})
+	assert.Check(t, cmp.DeepEqual(span.fields, map[string]interface{}{
+		"http.request.header.x_request_id":  "abc",
+		"http.request.header.cache_control": "no-cache, no-store",
+	}))
+}
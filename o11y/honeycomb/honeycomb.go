@@ -9,6 +9,7 @@ import (
 	"net/http"
 	"os"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/honeycombio/beeline-go"
@@ -307,11 +308,16 @@ func (h *honeycomb) AddGlobalField(key string, val interface{}) {
 	client.AddField(key, val)
 }
 
-func (h *honeycomb) StartSpan(ctx context.Context, name string, _ ...o11y.SpanOpt) (context.Context, o11y.Span) {
-	span := trace.GetSpanFromContext(ctx)
+func (h *honeycomb) StartSpan(ctx context.Context, name string, opts ...o11y.SpanOpt) (context.Context, o11y.Span) {
+	cfg := o11y.SpanConfig{}
+	for _, opt := range opts {
+		cfg = opt(cfg)
+	}
+
+	parent := trace.GetSpanFromContext(ctx)
 	var newSpan *trace.Span
-	if span != nil {
-		ctx, newSpan = span.CreateAsyncChild(ctx)
+	if parent != nil && !cfg.NewRoot {
+		ctx, newSpan = parent.CreateAsyncChild(ctx)
 	} else {
 		// there is no trace active; we should make one, but use the root span
 		// as the "new" span instead of creating a child of this mostly empty
@@ -320,18 +326,43 @@ func (h *honeycomb) StartSpan(ctx context.Context, name string, _ ...o11y.SpanOp
 		newSpan = trace.GetSpanFromContext(ctx)
 	}
 	newSpan.AddField("name", name)
+	s := &span{span: newSpan, onEnd: cfg.OnEnd}
+	for _, f := range cfg.Fields {
+		s.AddRawField(f.Key, f.Value)
+	}
 
-	return ctx, WrapSpan(newSpan)
+	return context.WithValue(ctx, spanCtxKey{}, s), s
 }
 
 // GetSpan returns the active span in the given context. It will return nil if there is no span available.
 func (h *honeycomb) GetSpan(ctx context.Context) o11y.Span {
-	return WrapSpan(trace.GetSpanFromContext(ctx))
+	s := getSpan(ctx)
+	if s == nil {
+		return nil
+	}
+	return s
+}
+
+type spanCtxKey struct{}
+
+// getSpan returns the wrapper of the active beeline span, which is the one the span was started with if
+// it was started by this provider, so that the wrapper state, e.g. for AppendField, is kept.
+func getSpan(ctx context.Context) *span {
+	bs := trace.GetSpanFromContext(ctx)
+	if bs == nil {
+		return nil
+	}
+	if s, ok := ctx.Value(spanCtxKey{}).(*span); ok && s.span == bs {
+		return s
+	}
+	return &span{span: bs}
 }
 
 func (h *honeycomb) AddField(ctx context.Context, key string, val interface{}) {
 	mustValidateKey(key)
-	beeline.AddField(ctx, key, val)
+	if s := getSpan(ctx); s != nil {
+		s.AddField(key, val)
+	}
 }
 
 func (h *honeycomb) AddFieldToTrace(ctx context.Context, key string, val interface{}) {
@@ -425,7 +456,8 @@ func (h helpers) InjectPropagation(ctx context.Context,
 	}
 
 	ctx, tr := trace.NewTrace(ctx, prop)
-	return ctx, WrapSpan(tr.GetRootSpan())
+	s := &span{span: tr.GetRootSpan()}
+	return context.WithValue(ctx, spanCtxKey{}, s), s
 }
 
 func (h helpers) TraceIDs(ctx context.Context) (traceID, parentID string) {
@@ -446,6 +478,12 @@ func WrapSpan(s *trace.Span) o11y.Span {
 type span struct {
 	span    *trace.Span
 	metrics []o11y.Metric
+	onEnd   []func(o11y.Span)
+
+	mu sync.Mutex
+	// fields are the values of the fields set via this wrapper, since beeline spans do not allow
+	// reading back existing fields, and AppendField needs the existing value
+	fields map[string]interface{}
 }
 
 func (s *span) AddField(key string, val interface{}) {
@@ -453,7 +491,7 @@ func (s *span) AddField(key string, val interface{}) {
 	if err, ok := val.(error); ok {
 		val = err.Error()
 	}
-	s.span.AddField("app."+key, val)
+	s.set("app."+key, val)
 }
 
 func (s *span) AddRawField(key string, val interface{}) {
@@ -461,6 +499,46 @@ func (s *span) AddRawField(key string, val interface{}) {
 	if err, ok := val.(error); ok {
 		val = err.Error()
 	}
+	s.set(key, val)
+}
+
+// AppendField appends val to the list in the key field. If the field already holds a single value,
+// it becomes the first element of the list.
+// N.B. only the fields set via this provider are known, since beeline spans do not allow reading back
+// existing fields, e.g. those added with the beeline package directly.
+func (s *span) AppendField(key string, val interface{}) {
+	mustValidateKey(key)
+	if err, ok := val.(error); ok {
+		val = err.Error()
+	}
+	key = "app." + key
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var list []interface{}
+	switch existing := s.fields[key].(type) {
+	case nil:
+	case []interface{}:
+		list = existing
+	default:
+		list = []interface{}{existing}
+	}
+	// a new list is made each time, since the previous one may be being sent with the span
+	list = append(list[:len(list):len(list)], val)
+	s.setLocked(key, list)
+}
+
+func (s *span) set(key string, val interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.setLocked(key, val)
+}
+
+func (s *span) setLocked(key string, val interface{}) {
+	if s.fields == nil {
+		s.fields = map[string]interface{}{}
+	}
+	s.fields[key] = val
 	s.span.AddField(key, val)
 }
 
@@ -471,6 +549,9 @@ func (s *span) RecordMetric(metric o11y.Metric) {
 }
 
 func (s *span) End() {
+	for _, fn := range s.onEnd {
+		fn(s)
+	}
 	s.span.Send()
 }
 
@@ -478,6 +559,23 @@ func (s *span) Flatten(string) {
 	// N.B. We are not implementing this feature in hc
 }
 
+func (s *span) StartTime() time.Time {
+	// N.B. beeline spans do not expose their start time, so we are not implementing this in hc
+	return time.Time{}
+}
+
+func (s *span) Context() o11y.SpanContext {
+	pc := s.span.PropagationContext()
+	if pc == nil {
+		return o11y.SpanContext{}
+	}
+	// N.B. hc sampling is decided as spans are sent, so Sampled is not known here
+	return o11y.SpanContext{
+		TraceID: pc.TraceID,
+		SpanID:  pc.ParentID,
+	}
+}
+
 func mustValidateKey(key string) {
 	if strings.Contains(key, "-") {
 		panic(fmt.Errorf("key %q cannot contain '-'", key))
@@ -401,6 +401,74 @@ func TestHoneycombWithNilError(t *testing.T) {
 	assert.Check(t, gotEvent, "expected to receive an event")
 }
 
+func TestHoneycomb_AppendField(t *testing.T) {
+	gotEvent := false
+	check := func(event string) {
+		gotEvent = true
+
+		assert.Check(t, cmp.Contains(event, `"app.list":["a","b","an error"]`))
+		assert.Check(t, cmp.Contains(event, `"app.other":[1]`))
+		assert.Check(t, cmp.Contains(event, `"app.single":["first","second"]`), "the scalar is the first element")
+		assert.Check(t, cmp.Contains(event, `"app.ctx_single":[1,2]`))
+	}
+	url := honeycombServer(t, check)
+	ctx := context.Background()
+
+	resetSamplerHook(t)
+	h := New(Config{
+		Dataset:     "append-dataset",
+		Host:        url,
+		SendTraces:  true,
+		Key:         "a-key",
+		ServiceName: "a-service-name",
+	})
+
+	ctx = o11y.WithProvider(ctx, h)
+	ctx, sp := o11y.StartSpan(ctx, "test-span-with-list")
+	sp.AppendField("list", "a")
+	// the span from the context is the same as the one started
+	h.GetSpan(ctx).AppendField("list", "b")
+	h.GetSpan(ctx).AppendField("list", errors.New("an error"))
+	sp.AppendField("other", 1)
+
+	sp.AddField("single", "first")
+	sp.AppendField("single", "second")
+	o11y.AddField(ctx, "ctx_single", 1)
+	h.GetSpan(ctx).AppendField("ctx_single", 2)
+	sp.End()
+	h.Close(ctx)
+
+	assert.Check(t, gotEvent, "expected to receive an event")
+}
+
+func TestHoneycomb_WithOnEnd(t *testing.T) {
+	gotEvent := false
+	check := func(event string) {
+		gotEvent = true
+
+		assert.Check(t, cmp.Contains(event, `"ended":true`))
+	}
+	url := honeycombServer(t, check)
+	ctx := context.Background()
+
+	resetSamplerHook(t)
+	h := New(Config{
+		Dataset:     "on-end-dataset",
+		Host:        url,
+		SendTraces:  true,
+		Key:         "a-key",
+		ServiceName: "a-service-name",
+	})
+
+	_, sp := h.StartSpan(ctx, "test-span-on-end", o11y.WithOnEnd(func(s o11y.Span) {
+		s.AddRawField("ended", true)
+	}))
+	sp.End()
+	h.Close(ctx)
+
+	assert.Check(t, gotEvent, "expected to receive an event")
+}
+
 func honeycombServer(t *testing.T, cb func(string)) string {
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		reader, err := zstd.NewReader(r.Body)
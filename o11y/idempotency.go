@@ -0,0 +1,18 @@
+package o11y
+
+import "context"
+
+// RecordIdempotencyKey adds the standard idempotency.key and idempotency.replayed fields to the currently
+// active span, recording whether the request was a replay of an earlier request with the same key.
+// The key is hashed with HashValue, since keys are high cardinality and may be derived from sensitive
+// data, but requests with the same key can still be matched up. The key is omitted if it is empty.
+func RecordIdempotencyKey(ctx context.Context, key string, replayed bool) {
+	span := FromContext(ctx).GetSpan(ctx)
+	if span == nil {
+		return
+	}
+	if key != "" {
+		span.AddRawField("idempotency.key", HashValue(key))
+	}
+	span.AddRawField("idempotency.replayed", replayed)
+}
@@ -0,0 +1,36 @@
+package o11y
+
+import (
+	"context"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+func TestRecordIdempotencyKey(t *testing.T) {
+	t.Run("without provider", func(t *testing.T) {
+		RecordIdempotencyKey(context.Background(), "key", true)
+	})
+
+	t.Run("adds fields", func(t *testing.T) {
+		ctx := WithProvider(context.Background(), &fakeProvider{})
+		ctx, span := StartSpan(ctx, "request")
+
+		RecordIdempotencyKey(ctx, "order-1234", true)
+		assert.Check(t, cmp.DeepEqual(span.(*fakeSpan).fields, map[string]interface{}{
+			"idempotency.key":      HashValue("order-1234"),
+			"idempotency.replayed": true,
+		}))
+	})
+
+	t.Run("empty key", func(t *testing.T) {
+		ctx := WithProvider(context.Background(), &fakeProvider{})
+		ctx, span := StartSpan(ctx, "request")
+
+		RecordIdempotencyKey(ctx, "", false)
+		assert.Check(t, cmp.DeepEqual(span.(*fakeSpan).fields, map[string]interface{}{
+			"idempotency.replayed": false,
+		}))
+	})
+}
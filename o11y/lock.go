@@ -0,0 +1,30 @@
+package o11y
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// TimedLock acquires l, adding the standard lock.name field and the time spent waiting to acquire it as
+// the lock.wait_ms field to the currently active span, so that latency from lock contention is visible.
+// It returns a func that releases l, which adds the time it was held as the lock.held_ms field.
+// Without an active span l is still acquired and released as usual, without timing.
+func TimedLock(ctx context.Context, name string, l sync.Locker) (unlock func()) {
+	span := FromContext(ctx).GetSpan(ctx)
+	if span == nil {
+		l.Lock()
+		return l.Unlock
+	}
+
+	start := time.Now()
+	l.Lock()
+	acquired := time.Now()
+	span.AddRawField("lock.name", name)
+	span.AddRawField("lock.wait_ms", float64(acquired.Sub(start))/float64(time.Millisecond))
+	return func() {
+		held := time.Since(acquired)
+		l.Unlock()
+		span.AddRawField("lock.held_ms", float64(held)/float64(time.Millisecond))
+	}
+}
@@ -0,0 +1,44 @@
+package o11y
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+func TestTimedLock(t *testing.T) {
+	t.Run("without provider", func(t *testing.T) {
+		var mu sync.Mutex
+		unlock := TimedLock(context.Background(), "noop", &mu)
+		assert.Check(t, !mu.TryLock())
+		unlock()
+		assert.Check(t, mu.TryLock())
+	})
+
+	t.Run("records wait and held", func(t *testing.T) {
+		ctx := WithProvider(context.Background(), &fakeProvider{})
+		ctx, span := StartSpan(ctx, "op")
+
+		var mu sync.Mutex
+		mu.Lock()
+		time.AfterFunc(20*time.Millisecond, mu.Unlock)
+
+		unlock := TimedLock(ctx, "cache", &mu)
+		time.Sleep(10 * time.Millisecond)
+		unlock()
+		assert.Check(t, mu.TryLock())
+
+		fields := span.(*fakeSpan).fields
+		assert.Check(t, cmp.Equal(fields["lock.name"], "cache"))
+		wait, ok := fields["lock.wait_ms"].(float64)
+		assert.Assert(t, ok)
+		assert.Check(t, wait >= 15, wait)
+		held, ok := fields["lock.held_ms"].(float64)
+		assert.Assert(t, ok)
+		assert.Check(t, held >= 10, held)
+	})
+}
@@ -9,13 +9,17 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"os"
 	"runtime/debug"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/DataDog/datadog-go/statsd"
 	"github.com/rollbar/rollbar-go"
 	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type Provider interface {
@@ -115,12 +119,29 @@ type Span interface {
 	// https://github.com/open-telemetry/opentelemetry-specification/tree/7ae3d066c95c716ef3086228ef955d84ba03ac88/specification/trace/semantic_conventions
 	AddRawField(key string, val interface{})
 
+	// AppendField is for adding application-level information to a list valued field on the span.
+	// Each call appends val to the list, rather than overwriting the previous value as AddField does.
+	// If the key already holds a single (non list) value, that value becomes the first element of the list.
+	//
+	// Any field name will be prefixed with "app."
+	AppendField(key string, val interface{})
+
 	// RecordMetric tells the provider to emit a metric to its metric backend when the span ends
 	RecordMetric(metric Metric)
 
 	// Flatten causes all child span attributes to be set on this span, with the given prefix
 	Flatten(prefix string)
 
+	// Context returns the provider agnostic identity of the span, see ContextFrom. Spans that will not be
+	// exported, e.g. those dropped by a head sampler, still have valid ids, so they can be used for log
+	// correlation. Sampled reports whether the span will be exported.
+	Context() SpanContext
+
+	// StartTime returns when the span started, for correlating spans with external time series data
+	// such as metrics or profiles. The end time is the start time plus the span duration.
+	// It is the zero time for providers that do not support it.
+	StartTime() time.Time
+
 	// End sets the duration of the span and tells the related provider that the span is complete,
 	// so it can do its appropriate processing. The span should not be used after End is called.
 	End()
@@ -203,22 +224,68 @@ type ClosableMetricsProvider interface {
 	io.Closer
 }
 
-type providerKey struct{}
+// providerKey is a string, rather than a private type, so that it is visible to any copy of this
+// package, e.g. where a diamond dependency has pulled in two major versions of this module. A provider
+// set by another copy does not implement this copy's Provider, which is how it is detected.
+const providerKey = "github.com/circleci/ex/o11y.provider"
+
+// otherCopyWarning is written to once, when a provider set by another copy of this package is detected
+var (
+	otherCopyWarning     io.Writer = os.Stderr
+	otherCopyWarningOnce sync.Once
+)
 
 // WithProvider returns a child context which contains the Provider. The Provider
 // can be retrieved with FromContext.
+//
+// N.B. the provider is only found by the same copy of this package. If a build includes more than one
+// copy, e.g. two major versions of this module, a provider set by one copy is not found by the other,
+// which falls back to the noop provider. A warning is written to stderr the first time this happens.
 func WithProvider(ctx context.Context, p Provider) context.Context {
-	return context.WithValue(ctx, providerKey{}, p)
+	return context.WithValue(ctx, providerKey, p) //nolint:staticcheck // see providerKey
+}
+
+// WithLibraryProvider returns a child context which contains fallback, unless the context already has
+// a provider, in which case ctx is returned unchanged. This is for libraries that want to emit spans by
+// default, without overriding the provider installed by the app using them, which takes precedence.
+func WithLibraryProvider(ctx context.Context, fallback Provider) context.Context {
+	if _, ok := ctx.Value(providerKey).(Provider); ok {
+		return ctx
+	}
+	return WithProvider(ctx, fallback)
 }
 
 // FromContext returns the provider stored in the context, or the default noop
 // provider if none exists.
 func FromContext(ctx context.Context) Provider {
-	provider, ok := ctx.Value(providerKey{}).(Provider)
+	provider, _ := ProviderFromContext(ctx)
+	return provider
+}
+
+// ProviderFromContext is the same as FromContext, but also reports whether a provider was found in
+// the context, as opposed to the default noop provider being returned. This allows initialization
+// code to check a provider has been installed with WithProvider.
+func ProviderFromContext(ctx context.Context) (Provider, bool) {
+	v := ctx.Value(providerKey)
+	provider, ok := v.(Provider)
 	if !ok {
-		return defaultProvider
+		if v != nil {
+			otherCopyWarningOnce.Do(func() {
+				_, _ = fmt.Fprintf(otherCopyWarning, "o11y: a provider (%T) was set by another copy of the "+
+					"github.com/circleci/ex/o11y package, so it is not visible to this copy. "+
+					"Check for multiple versions of the module in the build.\n", v)
+			})
+		}
+		return defaultProvider, false
 	}
-	return provider
+	return provider, true
+}
+
+// IsValidSpan returns true if the context carries a real otel span context, with both a valid
+// trace ID and span ID. It returns false if there is no span in the context, or if the span is
+// a noop span, which can be used to detect broken context propagation at service boundaries.
+func IsValidSpan(ctx context.Context) bool {
+	return trace.SpanContextFromContext(ctx).IsValid()
 }
 
 // Log sends a zero duration trace event.
@@ -226,9 +293,10 @@ func Log(ctx context.Context, name string, fields ...Pair) {
 	FromContext(ctx).Log(ctx, name, fields...)
 }
 
-// LogError sends a zero duration trace event with an error.
+// LogError sends a zero duration trace event with an error. The event is not given the fields scoped
+// with WithSpanField, or pending with WithPendingFields, which are left for the spans started after it.
 func LogError(ctx context.Context, name string, err error, fields ...Pair) {
-	_, span := StartSpan(ctx, name)
+	_, span := FromContext(ctx).StartSpan(ctx, name)
 	for _, f := range fields {
 		span.AddField(f.Key, f.Value)
 	}
@@ -237,8 +305,165 @@ func LogError(ctx context.Context, name string, err error, fields ...Pair) {
 }
 
 // StartSpan starts a span from a context that must contain a provider for this to have any effect.
+// Any fields scoped to the context with WithSpanField, or pending with WithPendingFields, are added to
+// the new span, along with any budget remaining from WithBudget, which the span spends as it ends.
 func StartSpan(ctx context.Context, name string, opts ...SpanOpt) (context.Context, Span) {
-	return FromContext(ctx).StartSpan(ctx, name, opts...)
+	ctx, budgetOpts := budgetSpanOpts(ctx)
+	if len(budgetOpts) > 0 {
+		opts = append(opts[:len(opts):len(opts)], budgetOpts...)
+	}
+	ctx, span := FromContext(ctx).StartSpan(ctx, name, opts...)
+	for _, f := range scopedSpanFields(ctx) {
+		span.AddField(f.Key, f.Value)
+	}
+	if pending, ok := ctx.Value(pendingFieldsKey{}).(*pendingFields); ok {
+		for _, f := range pending.take() {
+			span.AddField(f.Key, f.Value)
+		}
+	}
+	addBudgetField(ctx, span)
+	return ctx, span
+}
+
+// StartQueuedSpan starts a span in the same way as StartSpan, for work that was queued before it is
+// processed, e.g. in a worker pool. The time spent queued, from enqueuedAt until now, is added as the
+// queue.wait_ms field in (fractional) milliseconds, so it can be distinguished from the processing time,
+// which is the span duration. The field is omitted if enqueuedAt is the zero time.
+func StartQueuedSpan(ctx context.Context, name string, enqueuedAt time.Time, opts ...SpanOpt) (context.Context, Span) {
+	ctx, span := StartSpan(ctx, name, opts...)
+	if !enqueuedAt.IsZero() {
+		span.AddRawField("queue.wait_ms", float64(time.Since(enqueuedAt))/float64(time.Millisecond))
+	}
+	return ctx, span
+}
+
+// StartJobSpan starts the root span of a new trace for a run of the scheduled (e.g. cron) job, with the
+// standard job.name field. If scheduledAt is not the zero time, the job.scheduled_at field and the
+// job.delay_ms field, the time from scheduledAt until now, are also added, to surface scheduling delay.
+// The span kind is consumer, as the job is triggered asynchronously by its schedule.
+func StartJobSpan(ctx context.Context, jobName string, scheduledAt time.Time) (context.Context, Span) {
+	fields := []Pair{Field("job.name", jobName)}
+	if !scheduledAt.IsZero() {
+		fields = append(fields,
+			Field("job.scheduled_at", scheduledAt.UTC().Format(time.RFC3339Nano)),
+			Field("job.delay_ms", time.Since(scheduledAt).Milliseconds()),
+		)
+	}
+	return StartSpan(ctx, "job: "+jobName,
+		WithNewRoot(),
+		WithSpanKind(SpanKindConsumer),
+		WithStartFields(fields...),
+	)
+}
+
+// Start starts a span in the same way as StartSpan, but returns a single func that ends the span, for
+// call sites that prefer the `defer done()` shape, and avoids needing to capture the span:
+//
+//	ctx, done := o11y.Start(ctx, "GET /help")
+//	defer done()
+func Start(ctx context.Context, name string, opts ...SpanOpt) (context.Context, func()) {
+	ctx, span := StartSpan(ctx, name, opts...)
+	return ctx, span.End
+}
+
+// Timed returns a func that adds the time elapsed since Timed was called to the currently active
+// span, as the name_ms field in (fractional) milliseconds. It is a lighter weight alternative to a
+// child span for timing the phases of an operation, and is intended to be deferred like this..
+// defer o11y.Timed(ctx, "serialize")()
+func Timed(ctx context.Context, name string) func() {
+	span := FromContext(ctx).GetSpan(ctx)
+	if span == nil {
+		return func() {}
+	}
+	start := time.Now()
+	return func() {
+		span.AddField(name+"_ms", float64(time.Since(start))/float64(time.Millisecond))
+	}
+}
+
+// RequestIDHeader is the header the http middleware reads an incoming request id from.
+const RequestIDHeader = "X-Request-Id"
+
+type requestIDKey struct{}
+
+// WithRequestID returns a child context carrying the request id, typically one received in the
+// RequestIDHeader of an incoming request. Providers that add request ids to spans will reuse
+// this id rather than generating a new one.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	if id == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the request id carried in the context, or an empty string if there is none.
+// It is a stable correlation id that can be used in logs, even outside the tracing backend.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+type spanFieldsKey struct{}
+
+// WithSpanField adds a field to the currently active span, and returns a child context that causes
+// the field to also be added to every span started from it with StartSpan, including their children.
+// Spans started from contexts outside the returned context, such as siblings, are not affected.
+//
+// This is the scoped middle ground between AddField (a single span) and AddFieldToTrace (the whole trace).
+func WithSpanField(ctx context.Context, key string, val interface{}) context.Context {
+	AddField(ctx, key, val)
+
+	existing := scopedSpanFields(ctx)
+	fields := make([]Pair, 0, len(existing)+1)
+	fields = append(fields, existing...)
+	fields = append(fields, Field(key, val))
+	return context.WithValue(ctx, spanFieldsKey{}, fields)
+}
+
+func scopedSpanFields(ctx context.Context) []Pair {
+	fields, _ := ctx.Value(spanFieldsKey{}).([]Pair)
+	return fields
+}
+
+type pendingFieldsKey struct{}
+
+// pendingFields are added to the first span started, then discarded.
+type pendingFields struct {
+	mu     sync.Mutex
+	fields []Pair
+}
+
+func (p *pendingFields) take() []Pair {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	fields := p.fields
+	p.fields = nil
+	return fields
+}
+
+func (p *pendingFields) peek() []Pair {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.fields
+}
+
+// WithPendingFields returns a child context holding fields to be added to the next span started from it
+// with StartSpan, for fields that are known before the span is, e.g. request metadata parsed before the
+// routing that names the span. The fields are only added to the first span started, even if more are
+// started from the returned context, and are added along with any still pending from ctx.
+//
+// Only StartSpan in this package (and the helpers built on it) applies the fields. Spans started directly
+// with Provider.StartSpan, or with Helpers.InjectPropagation, as the root span of an incoming request is
+// by the middleware, do not get the fields, which stay pending for the next span started with StartSpan.
+func WithPendingFields(ctx context.Context, fields ...Pair) context.Context {
+	var existing []Pair
+	if pending, ok := ctx.Value(pendingFieldsKey{}).(*pendingFields); ok {
+		existing = pending.peek()
+	}
+	all := make([]Pair, 0, len(existing)+len(fields))
+	all = append(all, existing...)
+	all = append(all, fields...)
+	return context.WithValue(ctx, pendingFieldsKey{}, &pendingFields{fields: all})
 }
 
 // AddField adds a field to the currently active span
@@ -246,11 +471,88 @@ func AddField(ctx context.Context, key string, val interface{}) {
 	FromContext(ctx).AddField(ctx, key, val)
 }
 
+// AddFieldFunc adds a field to the currently active span, with the value returned by fn.
+// fn is only called if the field would be kept, that is there is an active span which is not
+// a noop span, and for providers that support it, the span is being recorded (i.e. it has not
+// been dropped by a head sampler). This saves computing expensive values that would be discarded.
+func AddFieldFunc(ctx context.Context, key string, fn func() interface{}) {
+	span := FromContext(ctx).GetSpan(ctx)
+	if span == nil {
+		return
+	}
+	if _, ok := span.(*noopSpan); ok {
+		return
+	}
+	if r, ok := span.(recordable); ok && !r.IsRecording() {
+		return
+	}
+	span.AddField(key, fn())
+}
+
+type recordable interface {
+	IsRecording() bool
+}
+
+// GetField returns the value of a field on the span, for providers that support reading fields back.
+// The key is as it appears on the span, so fields added with AddField have the "app." prefix.
+// The boolean is false if the field is not set, or the provider does not support it.
+func GetField(span Span, key string) (interface{}, bool) {
+	if g, ok := span.(fieldGetter); ok {
+		return g.GetField(key)
+	}
+	return nil, false
+}
+
+type fieldGetter interface {
+	GetField(key string) (interface{}, bool)
+}
+
+// FieldsSnapshot returns a copy of the fields currently on the span, keyed as they appear on the span
+// (see GetField), e.g. so tests or debug tooling can diff the fields before and after an operation.
+// It returns nil if the provider does not support reading fields back. This is an introspection aid,
+// copying every field, so it is not meant for the hot path.
+func FieldsSnapshot(span Span) map[string]interface{} {
+	if s, ok := span.(fieldsSnapshotter); ok {
+		return s.FieldsSnapshot()
+	}
+	return nil
+}
+
+type fieldsSnapshotter interface {
+	FieldsSnapshot() map[string]interface{}
+}
+
+// CopyFields copies the fields with the given keys from parent to child, e.g. so that a child span queried
+// in isolation has context fields from its parent that are not trace wide. Keys are as they appear on the
+// span (see GetField). Keys that are missing from the parent are skipped.
+func CopyFields(parent, child Span, keys ...string) {
+	for _, key := range keys {
+		if val, ok := GetField(parent, key); ok {
+			child.AddRawField(key, val)
+		}
+	}
+}
+
 // AddFieldToTrace adds a field to the currently active root span and all of its current and future child spans
 func AddFieldToTrace(ctx context.Context, key string, val interface{}) {
 	FromContext(ctx).AddFieldToTrace(ctx, key, val)
 }
 
+// AddFieldToTraceOK is the same as AddFieldToTrace, but reports whether there was an active trace
+// to add the field to. This allows callers to detect fields being added before a root span is started.
+func AddFieldToTraceOK(ctx context.Context, key string, val interface{}) bool {
+	p := FromContext(ctx)
+	span := p.GetSpan(ctx)
+	if span == nil {
+		return false
+	}
+	if _, ok := span.(*noopSpan); ok {
+		return false
+	}
+	p.AddFieldToTrace(ctx, key, val)
+	return true
+}
+
 // MakeSpanGolden Add a golden span from the span currently in the context.
 // If the golden trace does not exist it will be started.
 func MakeSpanGolden(ctx context.Context) context.Context {
@@ -276,22 +578,64 @@ func End(span Span, err *error) {
 }
 
 // AddResultToSpan takes a possibly nil error, and updates the "error" and "result" fields of the span appropriately.
+// See Result for the possible results. The span status is also set, see SetStatus. Cancellations leave
+// the status unset.
 func AddResultToSpan(span Span, err error) {
 	switch {
+	case IsPartial(err):
+		SetResult(span, ResultPartial)
+		SetStatus(span, StatusOK, "")
+		span.AddRawField("warning", err.Error())
+		return
 	case IsWarning(err):
 		span.AddRawField("warning", err.Error())
 	case errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded):
 		// Context cancellation and timeouts are expected, for instance in timeout and shutdown scenarios.
 		// Tracing as an error adds clutter when looking for real errors.
-		span.AddRawField("result", "canceled")
+		SetResult(span, ResultCanceled)
 		span.AddRawField("warning", err.Error())
 		return
 	case err != nil:
-		span.AddRawField("result", "error")
+		SetResult(span, ResultError)
+		SetStatus(span, StatusError, err.Error())
 		span.AddRawField("error", err.Error())
 		return
 	}
-	span.AddRawField("result", "success")
+	SetResult(span, ResultSuccess)
+	SetStatus(span, StatusOK, "")
+}
+
+// AddResult is the same as AddResultToSpan, for the currently active span. It does nothing if there
+// is no active span.
+func AddResult(ctx context.Context, err error) {
+	span := FromContext(ctx).GetSpan(ctx)
+	if span == nil {
+		return
+	}
+	AddResultToSpan(span, err)
+}
+
+// RecordError records a recoverable error on the currently active span, without marking the span as
+// failed, which is still done via End or AddResultToSpan. Providers that support it also count the
+// errors across the whole trace, so the root span gets errors.count and errors.first fields
+// summarising all the errors recorded anywhere in the trace.
+func RecordError(ctx context.Context, err error) {
+	if err == nil {
+		return
+	}
+	span := FromContext(ctx).GetSpan(ctx)
+	if span == nil {
+		return
+	}
+	if r, ok := span.(errorRecorder); ok {
+		r.RecordError(err)
+		return
+	}
+	span.AppendField("errors", err)
+}
+
+type errorRecorder interface {
+	RecordError(err error)
 }
 
 // Pair is a key value pair used to add metadata to a span.
@@ -411,9 +755,12 @@ type noopSpan struct{}
 
 func (s *noopSpan) AddField(key string, val interface{})    {}
 func (s *noopSpan) AddRawField(key string, val interface{}) {}
+func (s *noopSpan) AppendField(key string, val interface{}) {}
 func (s *noopSpan) RecordMetric(metric Metric)              {}
 func (s *noopSpan) End()                                    {}
 func (s *noopSpan) Flatten(string)                          {}
+func (s *noopSpan) Context() SpanContext                    { return SpanContext{} }
+func (s *noopSpan) StartTime() time.Time                    { return time.Time{} }
 
 func HandlePanic(ctx context.Context, span Span, panic interface{}, r *http.Request) (err error) {
 	err = fmt.Errorf("panic handled: %+v", panic)
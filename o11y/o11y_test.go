@@ -4,8 +4,12 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"go.opentelemetry.io/otel/trace"
 	"gotest.tools/v3/assert"
 	"gotest.tools/v3/assert/cmp"
 )
@@ -26,6 +30,50 @@ func TestFromContext(t *testing.T) {
 	})
 }
 
+func TestProviderFromContext_OtherCopy(t *testing.T) {
+	var b strings.Builder
+	origWriter := otherCopyWarning
+	otherCopyWarning = &b
+	otherCopyWarningOnce = sync.Once{}
+	t.Cleanup(func() { otherCopyWarning = origWriter })
+
+	// simulate a provider set by another copy of the package, which does not implement this copy's Provider
+	ctx := context.WithValue(context.Background(), providerKey, &otherCopyProvider{}) //nolint:staticcheck
+	_, ok := ProviderFromContext(ctx)
+	assert.Check(t, !ok)
+	_, ok = ProviderFromContext(ctx)
+	assert.Check(t, !ok)
+	assert.Check(t, cmp.Equal(strings.Count(b.String(), "another copy"), 1), b.String())
+	assert.Check(t, cmp.Contains(b.String(), "(*o11y.otherCopyProvider)"))
+
+	t.Run("same copy", func(t *testing.T) {
+		b.Reset()
+		otherCopyWarningOnce = sync.Once{}
+		_, ok := ProviderFromContext(WithProvider(context.Background(), &fakeProvider{}))
+		assert.Check(t, ok)
+		_, ok = ProviderFromContext(context.Background())
+		assert.Check(t, !ok)
+		assert.Check(t, cmp.Equal(b.String(), ""))
+	})
+}
+
+type otherCopyProvider struct{}
+
+func TestProviderFromContext(t *testing.T) {
+	t.Run("no provider", func(t *testing.T) {
+		p, ok := ProviderFromContext(context.Background())
+		assert.Check(t, !ok)
+		assert.Check(t, cmp.Equal(p, defaultProvider))
+	})
+
+	t.Run("with provider in context", func(t *testing.T) {
+		expected := &fakeProvider{}
+		p, ok := ProviderFromContext(WithProvider(context.Background(), expected))
+		assert.Check(t, ok)
+		assert.Check(t, cmp.Equal(p, Provider(expected)))
+	})
+}
+
 func TestLog_WithoutProvider(t *testing.T) {
 	ctx := context.Background()
 
@@ -38,6 +86,196 @@ func TestStartSpan_WithoutProvider(t *testing.T) {
 	nCtx, span := StartSpan(ctx, "foo")
 	assert.Check(t, span != nil, "should have returned a noop span")
 	assert.Check(t, cmp.Equal(ctx, nCtx), "should have returned ctx unmodified")
+	assert.Check(t, span.StartTime().IsZero())
+}
+
+func TestIsValidSpan(t *testing.T) {
+	t.Run("no span", func(t *testing.T) {
+		assert.Check(t, !IsValidSpan(context.Background()))
+	})
+
+	t.Run("noop span", func(t *testing.T) {
+		ctx, _ := StartSpan(context.Background(), "foo")
+		assert.Check(t, !IsValidSpan(ctx))
+	})
+
+	t.Run("invalid span id", func(t *testing.T) {
+		sc := trace.NewSpanContext(trace.SpanContextConfig{
+			TraceID: trace.TraceID{0x01},
+		})
+		ctx := trace.ContextWithSpanContext(context.Background(), sc)
+		assert.Check(t, !IsValidSpan(ctx))
+	})
+
+	t.Run("valid span", func(t *testing.T) {
+		sc := trace.NewSpanContext(trace.SpanContextConfig{
+			TraceID: trace.TraceID{0x01},
+			SpanID:  trace.SpanID{0x02},
+		})
+		ctx := trace.ContextWithSpanContext(context.Background(), sc)
+		assert.Check(t, IsValidSpan(ctx))
+	})
+}
+
+func TestSpanContext(t *testing.T) {
+	sc := SpanContext{
+		TraceID:    "0102030405060708090a0b0c0d0e0f10",
+		SpanID:     "0102030405060708",
+		Sampled:    true,
+		TraceState: "vendor=value",
+	}
+
+	t.Run("round trip", func(t *testing.T) {
+		b, err := sc.Marshal()
+		assert.NilError(t, err)
+
+		var got SpanContext
+		assert.NilError(t, got.Unmarshal(b))
+		assert.Check(t, cmp.DeepEqual(got, sc))
+	})
+
+	t.Run("context from", func(t *testing.T) {
+		ctx := ContextFrom(context.Background(), sc)
+		otelSC := trace.SpanContextFromContext(ctx)
+		assert.Check(t, otelSC.IsRemote())
+		assert.Check(t, otelSC.IsSampled())
+		assert.Check(t, cmp.Equal(otelSC.TraceID().String(), sc.TraceID))
+		assert.Check(t, cmp.Equal(otelSC.SpanID().String(), sc.SpanID))
+		assert.Check(t, cmp.Equal(otelSC.TraceState().String(), sc.TraceState))
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		ctx := context.Background()
+		assert.Check(t, cmp.Equal(ContextFrom(ctx, SpanContext{TraceID: "nope"}), ctx))
+		assert.Check(t, !SpanContextFromContext(ctx).IsValid())
+	})
+}
+
+func TestWithSpanField(t *testing.T) {
+	p := &fakeProvider{}
+	ctx := WithProvider(context.Background(), p)
+
+	ctx, root := StartSpan(ctx, "root")
+	scopedCtx := WithSpanField(ctx, "scoped", "yes")
+
+	childCtx, child := StartSpan(scopedCtx, "child")
+	_, grandchild := StartSpan(childCtx, "grandchild")
+	_, sibling := StartSpan(ctx, "sibling")
+
+	for _, span := range []Span{root, child, grandchild} {
+		assert.Check(t, cmp.Equal(span.(*fakeSpan).fields["app.scoped"], "yes"))
+	}
+	_, ok := sibling.(*fakeSpan).fields["app.scoped"]
+	assert.Check(t, !ok)
+}
+
+func TestStart(t *testing.T) {
+	t.Run("without provider", func(t *testing.T) {
+		ctx, done := Start(context.Background(), "foo")
+		assert.Check(t, ctx != nil)
+		done()
+	})
+
+	t.Run("ends span", func(t *testing.T) {
+		ctx := WithProvider(context.Background(), &fakeProvider{})
+		ctx, done := Start(ctx, "foo")
+
+		span := FromContext(ctx).GetSpan(ctx).(*fakeSpan)
+		assert.Check(t, !span.ended)
+		done()
+		assert.Check(t, span.ended)
+	})
+}
+
+func TestTimed(t *testing.T) {
+	t.Run("without provider", func(t *testing.T) {
+		Timed(context.Background(), "foo")()
+	})
+
+	t.Run("adds fields", func(t *testing.T) {
+		ctx := WithProvider(context.Background(), &fakeProvider{})
+		ctx, span := StartSpan(ctx, "foo")
+
+		done := Timed(ctx, "serialize")
+		time.Sleep(time.Millisecond)
+		done()
+		Timed(ctx, "write")()
+
+		fields := span.(*fakeSpan).fields
+		assert.Check(t, fields["app.serialize_ms"].(float64) >= 1)
+		assert.Check(t, fields["app.write_ms"].(float64) < fields["app.serialize_ms"].(float64))
+	})
+}
+
+func TestStartQueuedSpan(t *testing.T) {
+	t.Run("without provider", func(t *testing.T) {
+		_, span := StartQueuedSpan(context.Background(), "foo", time.Now())
+		span.End()
+	})
+
+	t.Run("adds wait", func(t *testing.T) {
+		ctx := WithProvider(context.Background(), &fakeProvider{})
+		_, span := StartQueuedSpan(ctx, "foo", time.Now().Add(-time.Second))
+		wait := span.(*fakeSpan).fields["queue.wait_ms"].(float64)
+		assert.Check(t, wait >= 1000 && wait < 60000, wait)
+	})
+
+	t.Run("zero enqueued time", func(t *testing.T) {
+		ctx := WithProvider(context.Background(), &fakeProvider{})
+		_, span := StartQueuedSpan(ctx, "foo", time.Time{})
+		assert.Check(t, cmp.Len(span.(*fakeSpan).fields, 0))
+	})
+}
+
+func TestStartJobSpan(t *testing.T) {
+	_, span := StartJobSpan(context.Background(), "cleanup", time.Now())
+	span.End()
+}
+
+func TestAddFieldFunc(t *testing.T) {
+	called := false
+	fn := func() interface{} {
+		called = true
+		return "expensive"
+	}
+
+	t.Run("noop provider", func(t *testing.T) {
+		AddFieldFunc(context.Background(), "key", fn)
+		assert.Check(t, !called)
+	})
+
+	t.Run("active span", func(t *testing.T) {
+		ctx := WithProvider(context.Background(), &fakeProvider{})
+		ctx, span := StartSpan(ctx, "foo")
+		AddFieldFunc(ctx, "key", fn)
+		assert.Check(t, called)
+		assert.Check(t, cmp.Equal(span.(*fakeSpan).fields["app.key"], "expensive"))
+	})
+}
+
+func TestAddFieldToTraceOK(t *testing.T) {
+	t.Run("noop provider", func(t *testing.T) {
+		assert.Check(t, !AddFieldToTraceOK(context.Background(), "key", "val"))
+	})
+
+	ctx := WithProvider(context.Background(), &fakeProvider{})
+	t.Run("no trace", func(t *testing.T) {
+		assert.Check(t, !AddFieldToTraceOK(ctx, "key", "val"))
+	})
+
+	t.Run("trace", func(t *testing.T) {
+		ctx, _ := StartSpan(ctx, "root")
+		assert.Check(t, AddFieldToTraceOK(ctx, "key", "val"))
+	})
+}
+
+func TestRequestID(t *testing.T) {
+	ctx := context.Background()
+	assert.Check(t, cmp.Equal(RequestID(ctx), ""))
+	assert.Check(t, cmp.Equal(WithRequestID(ctx, ""), ctx))
+
+	ctx = WithRequestID(ctx, "req-id")
+	assert.Check(t, cmp.Equal(RequestID(ctx), "req-id"))
 }
 
 func TestHandlePanic(t *testing.T) {
@@ -64,6 +302,7 @@ func TestAddResultToSpan(t *testing.T) {
 		result  string
 		error   string
 		warning string
+		status  StatusCode
 	}{
 		{
 			name:    "all-good",
@@ -71,6 +310,7 @@ func TestAddResultToSpan(t *testing.T) {
 			result:  "success",
 			error:   "",
 			warning: "",
+			status:  StatusOK,
 		},
 		{
 			name:    "normal-error",
@@ -78,6 +318,7 @@ func TestAddResultToSpan(t *testing.T) {
 			result:  "error",
 			error:   "my error",
 			warning: "",
+			status:  StatusError,
 		},
 		{
 			name:    "do-not-trace",
@@ -85,6 +326,7 @@ func TestAddResultToSpan(t *testing.T) {
 			result:  "success",
 			error:   "",
 			warning: "handled error",
+			status:  StatusOK,
 		},
 		{
 			name:    "wrapped-do-not-trace",
@@ -92,6 +334,7 @@ func TestAddResultToSpan(t *testing.T) {
 			result:  "success",
 			error:   "",
 			warning: "wrapped: warning error (odd pair of words)",
+			status:  StatusOK,
 		},
 		{
 			name:    "context-canceled",
@@ -99,6 +342,7 @@ func TestAddResultToSpan(t *testing.T) {
 			result:  "canceled",
 			error:   "",
 			warning: "context canceled",
+			status:  StatusUnset,
 		},
 		{
 			name:    "wrapped-context-canceled",
@@ -106,6 +350,7 @@ func TestAddResultToSpan(t *testing.T) {
 			result:  "canceled",
 			error:   "",
 			warning: "wrapped: context canceled",
+			status:  StatusUnset,
 		},
 		{
 			name:    "deadline-exceeded",
@@ -113,6 +358,7 @@ func TestAddResultToSpan(t *testing.T) {
 			result:  "canceled",
 			error:   "",
 			warning: "context deadline exceeded",
+			status:  StatusUnset,
 		},
 		{
 			name:    "wrapped-deadline-exceeded",
@@ -120,6 +366,15 @@ func TestAddResultToSpan(t *testing.T) {
 			result:  "canceled",
 			error:   "",
 			warning: "wrapped: context deadline exceeded",
+			status:  StatusUnset,
+		},
+		{
+			name:    "wrapped-partial",
+			err:     fmt.Errorf("wrapped: %w", RecordBatchResult(context.Background(), 2, 1, 1)),
+			result:  "partial",
+			error:   "",
+			warning: "wrapped: 1 of 2 batch items failed",
+			status:  StatusOK,
 		},
 	}
 
@@ -139,10 +394,27 @@ func TestAddResultToSpan(t *testing.T) {
 			checkField(span, "result", tt.result)
 			checkField(span, "error", tt.error)
 			checkField(span, "warning", tt.warning)
+			assert.Check(t, cmp.Equal(span.status, tt.status))
 		})
 	}
 }
 
+func TestAddResult(t *testing.T) {
+	t.Run("without provider", func(t *testing.T) {
+		AddResult(context.Background(), errors.New("my error"))
+	})
+
+	t.Run("active span", func(t *testing.T) {
+		ctx := WithProvider(context.Background(), &fakeProvider{})
+		ctx, span := StartSpan(ctx, "op")
+
+		AddResult(ctx, errors.New("my error"))
+		assert.Check(t, cmp.Equal(span.(*fakeSpan).fields["result"], "error"))
+		assert.Check(t, cmp.Equal(span.(*fakeSpan).fields["error"], "my error"))
+		assert.Check(t, cmp.Equal(span.(*fakeSpan).status, StatusError))
+	})
+}
+
 func newFakeSpan() *fakeSpan {
 	return &fakeSpan{fields: map[string]interface{}{}}
 }
@@ -150,8 +422,151 @@ func newFakeSpan() *fakeSpan {
 type fakeSpan struct {
 	Span
 	fields map[string]interface{}
+	ended  bool
+	status StatusCode
+	onEnd  []func(Span)
+}
+
+func (s *fakeSpan) End() {
+	for _, fn := range s.onEnd {
+		fn(s)
+	}
+	s.ended = true
+}
+
+func (s *fakeSpan) AddField(key string, val interface{}) {
+	s.fields["app."+key] = val
 }
 
 func (s *fakeSpan) AddRawField(key string, val interface{}) {
 	s.fields[key] = val
 }
+
+func (s *fakeSpan) AppendField(key string, val interface{}) {
+	list, _ := s.fields["app."+key].([]interface{})
+	s.fields["app."+key] = append(list, val)
+}
+
+func (s *fakeSpan) GetField(key string) (interface{}, bool) {
+	val, ok := s.fields[key]
+	return val, ok
+}
+
+func (s *fakeSpan) FieldsSnapshot() map[string]interface{} {
+	fields := make(map[string]interface{}, len(s.fields))
+	for k, v := range s.fields {
+		fields[k] = v
+	}
+	return fields
+}
+
+func (s *fakeSpan) SetStatus(code StatusCode, _ string) {
+	s.status = code
+}
+
+type fakeSpanKey struct{}
+
+// fakeProvider records fields on fakeSpans, so they can be inspected by tests
+type fakeProvider struct {
+	noopProvider
+}
+
+func (p *fakeProvider) StartSpan(ctx context.Context, _ string, opts ...SpanOpt) (context.Context, Span) {
+	span := newFakeSpan()
+	var cfg SpanConfig
+	for _, opt := range opts {
+		cfg = opt(cfg)
+	}
+	span.onEnd = cfg.OnEnd
+	return context.WithValue(ctx, fakeSpanKey{}, span), span
+}
+
+func (p *fakeProvider) GetSpan(ctx context.Context) Span {
+	if span, ok := ctx.Value(fakeSpanKey{}).(*fakeSpan); ok {
+		return span
+	}
+	return nil
+}
+
+func (p *fakeProvider) AddField(ctx context.Context, key string, val interface{}) {
+	if span := p.GetSpan(ctx); span != nil {
+		span.AddField(key, val)
+	}
+}
+
+func TestSetResult(t *testing.T) {
+	span := newFakeSpan()
+	SetResult(span, ResultCanceled)
+	assert.Check(t, cmp.Equal(span.fields["result"], "canceled"))
+}
+
+func TestFieldsSnapshot(t *testing.T) {
+	span := newFakeSpan()
+	span.AddField("handler", "build")
+	before := FieldsSnapshot(span)
+
+	span.AddRawField("http.method", "GET")
+	assert.Check(t, cmp.DeepEqual(before, map[string]interface{}{"app.handler": "build"}))
+	assert.Check(t, cmp.DeepEqual(FieldsSnapshot(span), map[string]interface{}{
+		"app.handler": "build",
+		"http.method": "GET",
+	}))
+
+	assert.Check(t, cmp.Nil(FieldsSnapshot(&noopSpan{})))
+}
+
+func TestCopyFields(t *testing.T) {
+	parent := newFakeSpan()
+	parent.AddField("handler", "build")
+	parent.AddRawField("http.method", "GET")
+	parent.AddField("other", "not copied")
+
+	child := newFakeSpan()
+	CopyFields(parent, child, "app.handler", "http.method", "app.missing")
+	assert.Check(t, cmp.DeepEqual(child.fields, map[string]interface{}{
+		"app.handler": "build",
+		"http.method": "GET",
+	}))
+
+	t.Run("unsupported-parent", func(t *testing.T) {
+		child := newFakeSpan()
+		CopyFields(&noopSpan{}, child, "app.handler")
+		assert.Check(t, cmp.Len(child.fields, 0))
+	})
+}
+
+func TestWithPendingFields(t *testing.T) {
+	ctx := WithProvider(context.Background(), &fakeProvider{})
+	ctx = WithPendingFields(ctx, Field("org", "circleci"))
+	ctx = WithPendingFields(ctx, Field("route", "/api"))
+
+	// log events do not consume the fields
+	LogError(ctx, "log", errors.New("an error"))
+
+	spanCtx, first := StartSpan(ctx, "first")
+	assert.Check(t, cmp.DeepEqual(first.(*fakeSpan).fields, map[string]interface{}{
+		"app.org":   "circleci",
+		"app.route": "/api",
+	}))
+
+	// consumed by the first span, so neither siblings nor children get them
+	_, sibling := StartSpan(ctx, "sibling")
+	assert.Check(t, cmp.Len(sibling.(*fakeSpan).fields, 0))
+	_, child := StartSpan(spanCtx, "child")
+	assert.Check(t, cmp.Len(child.(*fakeSpan).fields, 0))
+}
+
+func TestWithLibraryProvider(t *testing.T) {
+	fallback := &fakeProvider{}
+
+	t.Run("no provider", func(t *testing.T) {
+		ctx := WithLibraryProvider(context.Background(), fallback)
+		assert.Check(t, FromContext(ctx) == Provider(fallback))
+	})
+
+	t.Run("app provider wins", func(t *testing.T) {
+		app := &fakeProvider{}
+		ctx := WithLibraryProvider(WithProvider(context.Background(), app), fallback)
+		assert.Check(t, FromContext(ctx) == Provider(app))
+	})
+}
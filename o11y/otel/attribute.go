@@ -3,10 +3,101 @@ package otel
 import (
 	"fmt"
 	"reflect"
+	"sync"
+	"time"
 
 	"go.opentelemetry.io/otel/attribute"
 )
 
+// DurationUnit is the unit that time.Duration field values are recorded in, see Config.DurationUnit.
+type DurationUnit int
+
+const (
+	// DurationMilliseconds records durations as fractional milliseconds, with the _ms suffix
+	DurationMilliseconds DurationUnit = iota
+	// DurationSeconds records durations as fractional seconds, with the _s suffix
+	DurationSeconds
+	// DurationNanoseconds records durations as integer nanoseconds, with the _ns suffix
+	DurationNanoseconds
+)
+
+// Suffix returns the conventional field name suffix for durations in the unit, e.g. "_ms".
+func (u DurationUnit) Suffix() string {
+	switch u {
+	case DurationSeconds:
+		return "_s"
+	case DurationNanoseconds:
+		return "_ns"
+	default:
+		return "_ms"
+	}
+}
+
+func (u DurationUnit) value(d time.Duration) any {
+	switch u {
+	case DurationSeconds:
+		return d.Seconds()
+	case DurationNanoseconds:
+		return d.Nanoseconds()
+	default:
+		return float64(d) / float64(time.Millisecond)
+	}
+}
+
+// durationField returns the name of the automatic span duration field in the unit, e.g. duration_ms.
+func durationField(u DurationUnit) string {
+	return "duration" + u.Suffix()
+}
+
+// newSerializers returns the serializers with the default time.Duration conversion to unit registered.
+func newSerializers(unit DurationUnit) *serializers {
+	s := &serializers{}
+	s.register(reflect.TypeOf(time.Duration(0)), func(v any) any {
+		return unit.value(v.(time.Duration))
+	})
+	return s
+}
+
+// serializers are the registered conversions of field values of particular types, see RegisterSerializer.
+type serializers struct {
+	mu  sync.RWMutex
+	fns map[reflect.Type]func(any) any
+}
+
+func (s *serializers) register(t reflect.Type, fn func(any) any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.fns == nil {
+		s.fns = map[reflect.Type]func(any) any{}
+	}
+	s.fns[t] = fn
+}
+
+// serialize converts val with the serializer registered for its type, or the type it points to.
+// Values with no registered serializer are returned unchanged.
+func (s *serializers) serialize(val any) any {
+	if s == nil {
+		return val
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if len(s.fns) == 0 {
+		return val
+	}
+	t := reflect.TypeOf(val)
+	if fn, ok := s.fns[t]; ok {
+		return fn(val)
+	}
+	if t != nil && t.Kind() == reflect.Ptr {
+		if fn, ok := s.fns[t.Elem()]; ok && !isNil(val) {
+			return fn(deref(val))
+		}
+	}
+	return val
+}
+
 func attr(key string, vi any) attribute.KeyValue {
 	val := deref(vi)
 	switch v := val.(type) {
@@ -28,6 +119,12 @@ func attr(key string, vi any) attribute.KeyValue {
 		return attribute.Key(key).Float64(float64(v))
 	case float64:
 		return attribute.Key(key).Float64(v)
+	case []any:
+		vals := make([]string, len(v))
+		for i, e := range v {
+			vals[i] = attr(key, e).Value.Emit()
+		}
+		return attribute.Key(key).StringSlice(vals)
 	default:
 		if s, ok := val.(fmt.Stringer); ok {
 			if isNil(s) {
@@ -0,0 +1,140 @@
+package otel
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+const defaultAuditMaxBytes = 100 << 20
+
+// SpanSnapshot is a finished span, as written to the audit file.
+type SpanSnapshot struct {
+	Name       string         `json:"name"`
+	TraceID    string         `json:"trace_id"`
+	SpanID     string         `json:"span_id"`
+	ParentID   string         `json:"parent_id,omitempty"`
+	Start      time.Time      `json:"start"`
+	DurationMS float64        `json:"duration_ms"`
+	Fields     map[string]any `json:"fields"`
+}
+
+func snapshot(s sdktrace.ReadOnlySpan) SpanSnapshot {
+	ss := SpanSnapshot{
+		Name:       s.Name(),
+		TraceID:    s.SpanContext().TraceID().String(),
+		SpanID:     s.SpanContext().SpanID().String(),
+		Start:      s.StartTime(),
+		DurationMS: float64(s.EndTime().Sub(s.StartTime())) / float64(time.Millisecond),
+		Fields:     map[string]any{},
+	}
+	if s.Parent().IsValid() {
+		ss.ParentID = s.Parent().SpanID().String()
+	}
+	for _, attr := range s.Attributes() {
+		ss.Fields[string(attr.Key)] = attr.Value.AsInterface()
+	}
+	return ss
+}
+
+// auditExporter appends the spans that match the filter to a file as JSON lines. The file is rotated
+// when it reaches maxBytes, by renaming it with a timestamp suffix. Rotated files are never removed.
+type auditExporter struct {
+	path     string
+	filter   func(SpanSnapshot) bool
+	maxBytes int64
+
+	mu   sync.Mutex
+	f    *os.File
+	size int64
+}
+
+func newAuditExporter(path string, filter func(SpanSnapshot) bool, maxBytes int64) (*auditExporter, error) {
+	if maxBytes <= 0 {
+		maxBytes = defaultAuditMaxBytes
+	}
+	a := &auditExporter{
+		path:     path,
+		filter:   filter,
+		maxBytes: maxBytes,
+	}
+	if err := a.open(); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+func (a *auditExporter) open() error {
+	f, err := os.OpenFile(a.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("audit file: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return fmt.Errorf("audit file: %w", err)
+	}
+	a.f = f
+	a.size = info.Size()
+	return nil
+}
+
+func (a *auditExporter) rotate() error {
+	if err := a.f.Close(); err != nil {
+		return fmt.Errorf("audit file rotate: %w", err)
+	}
+	rotated := fmt.Sprintf("%s.%s", a.path, time.Now().UTC().Format("20060102T150405.000000000"))
+	if err := os.Rename(a.path, rotated); err != nil {
+		return fmt.Errorf("audit file rotate: %w", err)
+	}
+	return a.open()
+}
+
+func (a *auditExporter) ExportSpans(_ context.Context, spans []sdktrace.ReadOnlySpan) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.f == nil {
+		return nil
+	}
+	for _, s := range spans {
+		ss := snapshot(s)
+		if a.filter != nil && !a.filter(ss) {
+			continue
+		}
+		line, err := json.Marshal(ss)
+		if err != nil {
+			return fmt.Errorf("audit span: %w", err)
+		}
+		line = append(line, '\n')
+
+		if a.size > 0 && a.size+int64(len(line)) > a.maxBytes {
+			if err := a.rotate(); err != nil {
+				return err
+			}
+		}
+		n, err := a.f.Write(line)
+		a.size += int64(n)
+		if err != nil {
+			return fmt.Errorf("audit span: %w", err)
+		}
+	}
+	return nil
+}
+
+func (a *auditExporter) Shutdown(context.Context) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.f == nil {
+		return nil
+	}
+	err := a.f.Close()
+	a.f = nil
+	return err
+}
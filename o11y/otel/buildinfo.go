@@ -0,0 +1,32 @@
+package otel
+
+import (
+	"runtime/debug"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// buildInfoAttributes returns the resource attributes for the build info embedded in the binary,
+// see Config.DisableBuildInfo.
+func buildInfoAttributes() []attribute.KeyValue {
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return nil
+	}
+	var attrs []attribute.KeyValue
+	if bi.GoVersion != "" {
+		attrs = append(attrs, attribute.String("service.go_version", bi.GoVersion))
+	}
+	for _, s := range bi.Settings {
+		if s.Value == "" {
+			continue
+		}
+		switch s.Key {
+		case "vcs.revision":
+			attrs = append(attrs, attribute.String("service.vcs_revision", s.Value))
+		case "vcs.time":
+			attrs = append(attrs, attribute.String("service.vcs_time", s.Value))
+		}
+	}
+	return attrs
+}
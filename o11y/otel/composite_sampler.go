@@ -0,0 +1,77 @@
+package otel
+
+import (
+	"fmt"
+	"strings"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// SampleDecision is the outcome of a SubSampler.
+type SampleDecision int
+
+const (
+	// SampleUndecided defers the decision to the next SubSampler.
+	SampleUndecided SampleDecision = iota
+	SampleKeep
+	SampleDrop
+)
+
+// SubSampler is one strategy in a composite sampler, see NewCompositeSampler. It returns the decision
+// for the span, and the sample rate to record on the span if it is kept.
+type SubSampler interface {
+	Decide(p sdktrace.SamplingParameters) (SampleDecision, uint)
+}
+
+// SubSamplerFunc adapts a func to a SubSampler.
+type SubSamplerFunc func(p sdktrace.SamplingParameters) (SampleDecision, uint)
+
+func (f SubSamplerFunc) Decide(p sdktrace.SamplingParameters) (SampleDecision, uint) {
+	return f(p)
+}
+
+// RateSubSampler always decides, keeping 1 in rate traces, deterministically by trace ID.
+// It is typically the last SubSampler in a composite sampler.
+func RateSubSampler(rate uint) SubSampler {
+	return SubSamplerFunc(func(p sdktrace.SamplingParameters) (SampleDecision, uint) {
+		if shouldKeep(p.TraceID.String(), rate) {
+			return SampleKeep, rate
+		}
+		return SampleDrop, rate
+	})
+}
+
+// NewCompositeSampler returns a head sampler that asks each SubSampler in turn, with the first
+// to return SampleKeep or SampleDrop deciding. If every SubSampler is undecided the span is kept
+// with a sample rate of 1. Kept spans have the SampleRate attribute added.
+//
+// This allows policies to be composed from simple strategies that can each be tested in isolation,
+// for example "keep slow endpoints, then drop health checks, then 1 in 10 of everything else".
+// As with NewThresholdSampler, only fields set at span start are visible to the SubSamplers.
+func NewCompositeSampler(samplers ...SubSampler) sdktrace.Sampler {
+	return compositeSampler{samplers: samplers}
+}
+
+type compositeSampler struct {
+	samplers []SubSampler
+}
+
+func (s compositeSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	for _, sub := range s.samplers {
+		switch d, rate := sub.Decide(p); d {
+		case SampleKeep:
+			return headSamplingResult(p, true, rate)
+		case SampleDrop:
+			return headSamplingResult(p, false, rate)
+		}
+	}
+	return headSamplingResult(p, true, 1)
+}
+
+func (s compositeSampler) Description() string {
+	names := make([]string, 0, len(s.samplers))
+	for _, sub := range s.samplers {
+		names = append(names, fmt.Sprintf("%T", sub))
+	}
+	return fmt.Sprintf("CompositeSampler{%s}", strings.Join(names, ","))
+}
@@ -2,6 +2,7 @@ package otel
 
 import (
 	"context"
+	"strconv"
 
 	"go.opentelemetry.io/otel/attribute"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
@@ -11,11 +12,29 @@ var globalFields = Annotator{}
 
 // Annotator is a SpanProcessor that adds attributes to all started spans.
 type Annotator struct {
-	attrs []attribute.KeyValue
+	attrs  []attribute.KeyValue
+	canary bool
 }
 
 func (a *Annotator) addField(key string, value any) {
-	a.attrs = append(a.attrs, attr(key, value))
+	kv := attr(key, value)
+	a.attrs = append(a.attrs, kv)
+	if kv.Key == CanaryField {
+		// the last value added wins, as in OnStart
+		switch kv.Value.Type() {
+		case attribute.BOOL:
+			a.canary = kv.Value.AsBool()
+		case attribute.STRING:
+			a.canary, _ = strconv.ParseBool(kv.Value.AsString())
+		default:
+			a.canary = false
+		}
+	}
+}
+
+// isCanary reports if the canary field is true, as a bool or a string, e.g. "true"
+func (a *Annotator) isCanary() bool {
+	return a.canary
 }
 
 func (a Annotator) OnStart(_ context.Context, s sdktrace.ReadWriteSpan) {
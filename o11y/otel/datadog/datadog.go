@@ -0,0 +1,82 @@
+/*
+Package datadog exports spans from the otel provider to Datadog, so that the same instrumentation works
+for services using Datadog as well as Honeycomb.
+
+Spans are sent with OTLP to a Datadog Agent with OTLP ingest enabled, which forwards them to Datadog.
+The span status set by o11y.AddResultToSpan is mapped by the agent to the Datadog error flag, and the
+error and stack fields are mapped to the Datadog error.msg and error.stack conventions. This package has
+no Datadog dependencies.
+*/
+package datadog
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+
+	"github.com/circleci/ex/o11y/otel"
+)
+
+// DefaultAgentAddr is the default address of the Datadog Agent OTLP gRPC receiver.
+const DefaultAgentAddr = "localhost:4317"
+
+// fieldMapping maps o11y fields to the Datadog conventions
+var fieldMapping = map[attribute.Key]attribute.Key{
+	"error": "error.msg",
+	"stack": "error.stack",
+}
+
+// Configure returns a copy of conf, additionally exporting spans to the Datadog Agent OTLP gRPC receiver
+// at agentAddr, e.g. DefaultAgentAddr. The client connects lazily, so an unreachable agent does not fail
+// startup, see otel.Config OnExportError.
+func Configure(conf otel.Config, agentAddr string) (otel.Config, error) {
+	exporter, err := otlptrace.New(context.Background(), otlptracegrpc.NewClient(
+		otlptracegrpc.WithEndpoint(agentAddr),
+		otlptracegrpc.WithInsecure(),
+	))
+	if err != nil {
+		return conf, err
+	}
+	conf.Exporters = append(append([]sdktrace.SpanExporter{}, conf.Exporters...), newExporter(exporter))
+	return conf, nil
+}
+
+// exporter maps the span fields to the Datadog conventions before exporting them.
+type exporter struct {
+	sdktrace.SpanExporter
+}
+
+func newExporter(e sdktrace.SpanExporter) sdktrace.SpanExporter {
+	return exporter{SpanExporter: e}
+}
+
+func (e exporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	mapped := make([]sdktrace.ReadOnlySpan, len(spans))
+	for i, s := range spans {
+		mapped[i] = mappedSpan{ReadOnlySpan: s, attrs: mapAttributes(s.Attributes())}
+	}
+	return e.SpanExporter.ExportSpans(ctx, mapped)
+}
+
+func mapAttributes(attrs []attribute.KeyValue) []attribute.KeyValue {
+	mapped := make([]attribute.KeyValue, len(attrs))
+	for i, a := range attrs {
+		if k, ok := fieldMapping[a.Key]; ok {
+			a.Key = k
+		}
+		mapped[i] = a
+	}
+	return mapped
+}
+
+type mappedSpan struct {
+	sdktrace.ReadOnlySpan
+	attrs []attribute.KeyValue
+}
+
+func (s mappedSpan) Attributes() []attribute.KeyValue {
+	return s.attrs
+}
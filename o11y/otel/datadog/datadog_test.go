@@ -0,0 +1,57 @@
+package datadog
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+
+	"github.com/circleci/ex/internal/syncbuffer"
+	"github.com/circleci/ex/o11y"
+	"github.com/circleci/ex/o11y/otel"
+)
+
+// keepOnShutdown stops the in memory exporter discarding its spans as the provider is closed
+type keepOnShutdown struct {
+	*tracetest.InMemoryExporter
+}
+
+func (keepOnShutdown) Shutdown(context.Context) error { return nil }
+
+func TestExporter(t *testing.T) {
+	mem := tracetest.NewInMemoryExporter()
+	op, err := otel.New(otel.Config{
+		Writer:    &syncbuffer.SyncBuffer{},
+		Exporters: []sdktrace.SpanExporter{newExporter(keepOnShutdown{mem})},
+	})
+	assert.NilError(t, err)
+	ctx := o11y.WithProvider(context.Background(), op)
+
+	_, span := o11y.StartSpan(ctx, "failing")
+	span.AddRawField("stack", "goroutine 1")
+	o11y.AddResultToSpan(span, errors.New("oh noes"))
+	span.End()
+	op.Close(ctx)
+
+	spans := mem.GetSpans().Snapshots()
+	assert.Assert(t, cmp.Len(spans, 1))
+	attrs := map[string]string{}
+	for _, a := range spans[0].Attributes() {
+		attrs[string(a.Key)] = a.Value.Emit()
+	}
+	assert.Check(t, cmp.Equal(attrs["error.msg"], "oh noes"))
+	assert.Check(t, cmp.Equal(attrs["error.stack"], "goroutine 1"))
+	assert.Check(t, cmp.Equal(attrs["result"], "error"))
+	_, ok := attrs["error"]
+	assert.Check(t, !ok)
+}
+
+func TestConfigure(t *testing.T) {
+	conf, err := Configure(otel.Config{}, DefaultAgentAddr)
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Len(conf.Exporters, 1))
+}
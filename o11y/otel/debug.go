@@ -0,0 +1,98 @@
+package otel
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"reflect"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"sync"
+)
+
+// DebugPropagationEnv is the environment variable that enables the context propagation debugging mode,
+// when set to any non-empty value.
+//
+// In this mode, starting a span with a context that has no parent span, on a goroutine that already has
+// spans in flight, writes a warning with a stack trace pointing at the likely site where the context was
+// dropped (e.g. a stray context.Background()). This is a heuristic, so it may give false positives, and
+// it has a cost on every span, so should not be enabled in production.
+const DebugPropagationEnv = "O11Y_DEBUG_PROPAGATION"
+
+// DebugFieldCollisionsEnv is the environment variable that enables the field collision debugging mode,
+// when set to any non-empty value.
+//
+// In this mode, adding a field to a span that already has the field with a different value writes a
+// warning with both values, to catch different layers (e.g. middleware and handlers) accidentally using
+// the same key for different things. Some fields are intentionally overwritten, so not every warning is
+// a mistake. This has a cost on every field, so should not be enabled in production.
+const DebugFieldCollisionsEnv = "O11Y_DEBUG_FIELD_COLLISIONS"
+
+// checkCollision writes a warning if the span already has a different value for key.
+// N.B. s.mu must be held.
+func (s *span) checkCollision(key string, val any) {
+	old, ok := s.fields[key]
+	if !ok || reflect.DeepEqual(old, val) {
+		return
+	}
+	_, _ = fmt.Fprintf(s.collisions, "o11y: field %q on span %q overwritten: %v replaced by %v\n",
+		key, s.name, old, val)
+}
+
+// breadcrumbs tracks the number of in flight spans started on each goroutine
+type breadcrumbs struct {
+	w io.Writer
+
+	mu       sync.Mutex
+	inFlight map[uint64]int
+}
+
+func newBreadcrumbs(w io.Writer) *breadcrumbs {
+	return &breadcrumbs{
+		w:        w,
+		inFlight: map[uint64]int{},
+	}
+}
+
+// started records a span starting on the current goroutine, returning the goroutine id.
+// If the span is a root, and there are other spans in flight on the goroutine, a warning is written.
+func (b *breadcrumbs) started(name string, root bool) uint64 {
+	gid := goroutineID()
+
+	b.mu.Lock()
+	inFlight := b.inFlight[gid]
+	b.inFlight[gid]++
+	b.mu.Unlock()
+
+	if root && inFlight > 0 {
+		_, _ = fmt.Fprintf(b.w,
+			"o11y: span %q started with no parent span, but %d span(s) are in flight on this goroutine."+
+				" The context may have been dropped:\n%s\n", name, inFlight, debug.Stack())
+	}
+	return gid
+}
+
+// ended records a span, started on goroutine gid, ending
+func (b *breadcrumbs) ended(gid uint64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.inFlight[gid]--
+	if b.inFlight[gid] <= 0 {
+		delete(b.inFlight, gid)
+	}
+}
+
+// goroutineID parses the current goroutine id from the first line of its stack trace
+// e.g. "goroutine 123 [running]:"
+func goroutineID() uint64 {
+	var buf [64]byte
+	n := runtime.Stack(buf[:], false)
+	f := bytes.Fields(bytes.TrimPrefix(buf[:n], []byte("goroutine ")))
+	if len(f) == 0 {
+		return 0
+	}
+	id, _ := strconv.ParseUint(string(f[0]), 10, 64)
+	return id
+}
@@ -0,0 +1,53 @@
+package otel
+
+import (
+	"context"
+
+	"google.golang.org/grpc/stats"
+
+	"github.com/circleci/ex/o11y"
+)
+
+// Compression is the compression of spans exported with OTLP, see Config.Compression.
+type Compression string
+
+const (
+	CompressionNone Compression = "none"
+	CompressionGzip Compression = "gzip"
+)
+
+// exportStats is a grpc stats handler that counts the bytes of exported payloads, as the
+// otel.export.bytes metric, compressed, and the otel.export.uncompressed_bytes metric.
+type exportStats struct {
+	mp   o11y.MetricsProvider
+	tags []string
+}
+
+func newExportStats(mp o11y.MetricsProvider, compression Compression) *exportStats {
+	if compression == "" {
+		compression = CompressionNone
+	}
+	return &exportStats{
+		mp:   mp,
+		tags: []string{fmtTag("exporter", "grpc"), fmtTag("compression", string(compression))},
+	}
+}
+
+func (e *exportStats) HandleRPC(_ context.Context, s stats.RPCStats) {
+	out, ok := s.(*stats.OutPayload)
+	if !ok {
+		return
+	}
+	_ = e.mp.Count("otel.export.bytes", int64(out.CompressedLength), e.tags, 1)
+	_ = e.mp.Count("otel.export.uncompressed_bytes", int64(out.Length), e.tags, 1)
+}
+
+func (e *exportStats) TagRPC(ctx context.Context, _ *stats.RPCTagInfo) context.Context {
+	return ctx
+}
+
+func (e *exportStats) TagConn(ctx context.Context, _ *stats.ConnTagInfo) context.Context {
+	return ctx
+}
+
+func (e *exportStats) HandleConn(context.Context, stats.ConnStats) {}
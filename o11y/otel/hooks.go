@@ -0,0 +1,122 @@
+package otel
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// endHook is called as each span ends, after the span duration and trace fields have been set,
+// but before the span is exported, so hooks can add fields to the span.
+//
+// As a span ends, its fields are completed in this order:
+//  1. the duration field (e.g. duration_ms, see Config.DurationUnit), and the trace fields
+//     (see o11y.AddFieldToTrace)
+//  2. any collapsed Log events (see Config.LogDedupThreshold)
+//  3. the end hooks, in the order TimingBreakdown, ChildCounts, CutShortFields, for those enabled,
+//     followed by any funcs the span was started with, see o11y.WithOnEnd
+//  4. the trace summary fields, on (local) root spans
+//  5. the span metrics
+//
+// The span is then handed to the span processors in the order the exporters (batched, unless SyncExport),
+// the AuditFile and the RecentTraces. A panic in a hook is recovered, so it does not stop the other hooks
+// or the span being exported, and is counted, see Provider.EndHookPanics.
+type endHook func(s *span)
+
+func (s *span) runEndHooks() {
+	for _, h := range s.endHooks {
+		s.runEndHook(h)
+	}
+	for _, fn := range s.onEnd {
+		s.runEndHook(func(s *span) { fn(s) })
+	}
+}
+
+func (s *span) runEndHook(h endHook) {
+	defer func() {
+		if r := recover(); r != nil && s.hookPanics != nil {
+			s.hookPanics.Add(1)
+		}
+	}()
+	h(s)
+}
+
+// timings aggregates the total duration of the spans in a trace by span name
+type timings struct {
+	mu        sync.Mutex
+	durations map[string]time.Duration
+}
+
+func (t *timings) add(name string, d time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.durations == nil {
+		t.durations = map[string]time.Duration{}
+	}
+	t.durations[name] += d
+}
+
+// breakdown formats the timings sorted by name e.g. "cache: 5ms, db: 40ms, http: 120ms"
+func (t *timings) breakdown() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	names := make([]string, 0, len(t.durations))
+	for name := range t.durations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		parts = append(parts, fmt.Sprintf("%s: %dms", name, t.durations[name].Milliseconds()))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// timingBreakdownHook aggregates the durations of the child spans of a trace by name, and adds the
+// summary to the (local) root span as the timing.breakdown field when it ends.
+// This is approximate, since it ignores the parallelism of child spans.
+func timingBreakdownHook(s *span) {
+	if s.tr == nil {
+		return
+	}
+	if s.parent != nil {
+		s.tr.timings.add(s.name, time.Since(s.start))
+		return
+	}
+	if b := s.tr.timings.breakdown(); b != "" {
+		s.AddRawField("timing.breakdown", b)
+	}
+}
+
+// childCountHook adds the number of direct children started from the span as span.child_count, and
+// the number of spans started in the whole trace to the (local) root span as trace.span_count.
+func childCountHook(s *span) {
+	if n := s.children.Load(); n > 0 {
+		s.AddRawField("span.child_count", n)
+	}
+	if s.parent == nil && s.tr != nil {
+		s.AddRawField("trace.span_count", s.tr.spans.Load())
+	}
+}
+
+// cutShortHook adds the cut_short and cut_short.cause fields to a span whose context was cancelled
+// before it ended, unless it has a result.
+func cutShortHook(s *span) {
+	if s.ctx == nil || s.ctx.Err() == nil {
+		return
+	}
+	s.mu.RLock()
+	_, hasResult := s.fields["result"]
+	s.mu.RUnlock()
+	if hasResult {
+		return
+	}
+	s.AddRawField("cut_short", true)
+	s.AddRawField("cut_short.cause", context.Cause(s.ctx).Error())
+}
@@ -0,0 +1,36 @@
+package otel
+
+import (
+	"context"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+
+	"github.com/circleci/ex/internal/syncbuffer"
+	"github.com/circleci/ex/o11y"
+)
+
+func TestEndHooks_Panic(t *testing.T) {
+	buf := &syncbuffer.SyncBuffer{}
+	op, err := New(Config{Writer: buf, SyncExport: true, ChildCounts: true})
+	assert.NilError(t, err)
+	p := op.(*Provider)
+
+	var after []string
+	p.endHooks = append([]endHook{
+		func(s *span) { panic("broken hook") },
+		func(s *span) { after = append(after, s.name) },
+	}, p.endHooks...)
+
+	ctx := o11y.WithProvider(context.Background(), p)
+	ctx, root := o11y.StartSpan(ctx, "root")
+	_, child := o11y.StartSpan(ctx, "child")
+	child.End()
+	root.End()
+
+	// the other hooks still ran, including the child count hook, and the spans were exported
+	assert.Check(t, cmp.DeepEqual(after, []string{"child", "root"}))
+	assert.Check(t, cmp.Contains(buf.String(), "root span.child_count=1"))
+	assert.Check(t, cmp.Equal(p.EndHookPanics(), int64(2)))
+}
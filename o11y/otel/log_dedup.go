@@ -0,0 +1,76 @@
+package otel
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/circleci/ex/o11y"
+)
+
+// collapsedLogs tracks the Log events sent with a span as their parent, see Config.LogDedupThreshold
+type collapsedLogs struct {
+	mu     sync.Mutex
+	events map[string]*collapsedLog
+}
+
+type collapsedLog struct {
+	sent  int
+	count int
+	first time.Time
+	last  time.Time
+	// emit sends the collapsed event, with the fields of the first event held back
+	emit func(raw []o11y.Pair)
+}
+
+// record reports whether the named event should be sent now. Otherwise it is held back, to be sent
+// collapsed by flush, with emit.
+func (l *collapsedLogs) record(name string, threshold int, emit func(raw []o11y.Pair)) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.events == nil {
+		l.events = map[string]*collapsedLog{}
+	}
+	e, ok := l.events[name]
+	if !ok {
+		e = &collapsedLog{}
+		l.events[name] = e
+	}
+	if e.sent < threshold {
+		e.sent++
+		return true
+	}
+	now := time.Now()
+	if e.count == 0 {
+		e.first = now
+		e.emit = emit
+	}
+	e.count++
+	e.last = now
+	return false
+}
+
+// flush sends a single collapsed event for each event name that had events held back
+func (l *collapsedLogs) flush() {
+	l.mu.Lock()
+	events := l.events
+	l.events = nil
+	l.mu.Unlock()
+
+	names := make([]string, 0, len(events))
+	for name, e := range events {
+		if e.count > 0 {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		e := events[name]
+		e.emit([]o11y.Pair{
+			o11y.Field("count", e.count),
+			o11y.Field("first_at", e.first.UTC().Format(time.RFC3339Nano)),
+			o11y.Field("last_at", e.last.UTC().Format(time.RFC3339Nano)),
+		})
+	}
+}
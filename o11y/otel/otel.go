@@ -5,15 +5,22 @@ package otel
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
+	"reflect"
+	"regexp"
+	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/google/uuid"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
@@ -22,6 +29,8 @@ import (
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
 	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	_ "google.golang.org/grpc/encoding/gzip" // registers the gzip compressor for CompressionGzip
 
 	"github.com/circleci/ex/config/secret"
 	"github.com/circleci/ex/o11y"
@@ -38,32 +47,232 @@ type Config struct {
 	// HTTPAuthorization is the authorization token to send with http requests
 	HTTPAuthorization secret.String
 
-	ResourceAttributes []attribute.KeyValue
+	// Compression of the exported spans, either CompressionNone (the default) or CompressionGzip.
+	Compression Compression
 
-	SampleTraces  bool
+	ResourceAttributes []attribute.KeyValue
+	// DisableBuildInfo stops the service.go_version, service.vcs_revision and service.vcs_time resource
+	// attributes being added from the build info embedded in the binary, so that every span records the
+	// commit it was built from. Any that are unavailable, e.g. with go run, are omitted.
+	DisableBuildInfo bool
+
+	SampleTraces bool
+	// SampleKeyFunc is given the span attributes, plus the span name as "span.name". The span name is also
+	// given as "name", unless the span has a "name" attribute of its own, which takes precedence.
 	SampleKeyFunc func(map[string]any) string
 	SampleRates   map[string]uint
+	// SampleKeyFields optionally declares the only fields SampleKeyFunc uses, so that only those are
+	// extracted from each span, rather than all of its attributes. If "span.name" is the only field
+	// the span attributes are not read at all. See BenchmarkShouldSample.
+	SampleKeyFields []string
+
+	// SampleByTraceID makes the SampleTraces keep decision from the trace id rather than the span id, so
+	// that spans in a trace with the same sample rate share the same decision.
+	SampleByTraceID bool
+	// SampleSeed is optionally mixed into the hash the SampleTraces keep decision is made from. Services
+	// with different seeds make independent decisions for the same trace id, e.g. for sampling experiments,
+	// while services sharing a seed make the same decisions. N.B. changing the seed reshuffles which
+	// traces are kept.
+	SampleSeed string
+	// SamplingDebug adds a sampling.determinant field to spans kept by SampleTraces, recording the kind of
+	// id the decision was made from and its hash, e.g. "trace_id:1a2b3c4d". This is a debug aid for checking
+	// the sampling behaves as configured, and should not normally be enabled in production.
+	SamplingDebug bool
+	// SampleDryRun makes SampleTraces keep every span, for previewing the effect of sample rates without
+	// dropping any data. Spans that would have been dropped have the sampling.would_drop field set to
+	// true, and every span is exported with a SampleRate of 1. N.B. this exports the full span volume.
+	SampleDryRun bool
+
+	// Sampler is an optional head sampler, applied as spans are started e.g. NewRatioSampler.
+	// It is independent of SampleTraces, which is applied as spans are exported.
+	// Both samplers keep every span on canary instances, see CanaryField.
+	Sampler sdktrace.Sampler
+	// PropagateSampleRate propagates the SampleRate of root spans kept by the head Sampler in the W3C
+	// tracestate, so that the other spans in the trace, including those in downstream services (which
+	// must also enable this), record the same SampleRate. Otherwise, spans kept because their parent
+	// was, e.g. with sdktrace.ParentBased, have no sample rate, which skews reconstructed counts.
+	// Spans with a sample rate of their own keep it.
+	PropagateSampleRate bool
+
+	// RequestIDs causes a request.id field to be added to every span. The id is generated when
+	// the root span is started, unless the context already carries one (see o11y.WithRequestID),
+	// and it is inherited by all child spans. The id is available via o11y.RequestID.
+	RequestIDs bool
+	// RequestIDGenerator optionally overrides the default random UUID request ids.
+	RequestIDGenerator func() string
+
+	// RedactPatterns opts in to scrubbing matches of these patterns from all string field values, before
+	// they are stored on the span. This is a defense in depth measure for secrets that may turn up in any
+	// field. See DefaultRedactPatterns. Each pattern adds some cost to every string field added, microseconds
+	// for the default patterns on typical values (see BenchmarkRedactor).
+	RedactPatterns []*regexp.Regexp
+
+	// TimingBreakdown adds a timing.breakdown field to each (local) root span, summarising the total
+	// time spent in the child spans of the trace by span name, e.g. "db: 40ms, http: 120ms".
+	// This is approximate, since it ignores any parallelism, but is useful for at a glance latency attribution.
+	TimingBreakdown bool
+
+	// ChildCounts adds a span.child_count field to each span that has child spans, counting its direct
+	// children, and a trace.span_count field to each (local) root span, counting all the spans in the
+	// trace. This is a cheap signal for fan out, e.g. N+1 query patterns. Children that are started after
+	// their parent has ended are not counted.
+	ChildCounts bool
+
+	// CutShortFields adds a cut_short=true field to spans whose context was cancelled before they ended,
+	// without a result being recorded (see o11y.AddResultToSpan), along with the cancellation cause
+	// (see context.Cause) as the cut_short.cause field. This shows which work was aborted by a cascading
+	// cancellation, e.g. a whole subtree of spans after a client disconnects.
+	CutShortFields bool
+
+	// ParentIDField adds a trace.parent_id field to each span with a parent, including a remote parent,
+	// set to the parent span id. Honeycomb renders its waterfall from this field, so this makes the
+	// parenting explicit for ingestion paths that do not preserve the otel parent span id.
+	ParentIDField bool
+
+	// SampledFields marks high cardinality fields that should only be recorded on a fraction of spans.
+	// The map is from the field key as it appears on the span (e.g. "app.user_id") to the sample rate,
+	// so a rate of 100 records the field on 1 in 100 spans. The decision is made deterministically by
+	// span id, so a span either has all of its sampled fields with the same rate, or none of them.
+	// Other fields are always recorded.
+	SampledFields map[string]uint
+
+	// IDGenerator optionally overrides the random trace and span ids, e.g. with o11ytest.NewIDGenerator
+	// for predictable ids in tests.
+	IDGenerator sdktrace.IDGenerator
+
+	// MaxSpanDepth optionally limits the depth of nested spans, to protect against runaway recursion.
+	// Beyond this depth, starting a span returns the parent span instead of a new child, and the parent
+	// gets a depth.truncated field counting the spans that were not started. Ending the returned span
+	// does not end the parent.
+	MaxSpanDepth int
+
+	// BaggageFields names baggage entries that are copied onto every span as it is started, so that
+	// values propagated between services as baggage are also queryable fields on every span. As with
+	// o11y.WithBaggage, dashes in the names are replaced with underscores in the field keys.
+	BaggageFields []string
+
+	// NameValidator is optionally called with the name of each span as it is started, to help enforce span
+	// naming conventions, e.g. "METHOD /route". If it returns an error the span is still started, but it gets
+	// name.invalid=true and name.invalid_reason fields. This is intended for development and test environments,
+	// and should typically be left nil in production to avoid the cost of validating every span.
+	NameValidator func(name string) error
+
+	// LogSampleRate samples the zero duration spans sent by Log, keeping 1 in every LogSampleRate,
+	// independently of any span sampling. Dropped events are counted in a logs.sampled_out field on
+	// the (local) root span. Events sent by o11y.LogError are never dropped.
+	LogSampleRate uint
+	// LogDedupThreshold collapses repeated Log events per parent span into one after this many (0 is off).
+	LogDedupThreshold int
+
+	// MaxTraceFields caps the number of distinct fields that can be added with AddFieldToTrace, since every
+	// trace field is added to every span in the trace. Additional fields are dropped, and counted in the
+	// trace_fields.dropped field on the (local) root span. Defaults to 100.
+	MaxTraceFields int
+
+	// InheritedFields optionally restricts which fields added with AddFieldToTrace are propagated to
+	// child spans. Trace fields not in this list are only added to the (local) root span.
+	// If empty, all trace fields are added to every span.
+	InheritedFields []string
+
+	// OnExportError is optionally called with any error returned when exporting spans, for instance
+	// auth or connection failures, which would otherwise be swallowed by the batch span processor.
+	// It is also called with any errors flushing spans and metrics on Close.
+	// See also Provider.LastExportError.
+	OnExportError func(error)
+
+	// AuditFile optionally names a file that finished spans are appended to as JSON lines, for retaining
+	// a local copy of spans, e.g. for compliance. This is in addition to the normal export of spans, and
+	// is not subject to SampleTraces. Only spans matching AuditFilter are written, if it is set.
+	AuditFile   string
+	AuditFilter func(SpanSnapshot) bool
+	// AuditMaxBytes is the size at which the audit file is rotated, defaulting to 100MiB. The rotated
+	// files have a timestamp suffix, and are never removed.
+	AuditMaxBytes int64
+
+	// DurationUnit is the unit time.Duration field values are recorded in, as numbers, defaulting to
+	// fractional milliseconds. Field names should have the matching DurationUnit.Suffix, e.g. wait_ms.
+	// The automatic span duration field is also in the unit, named with its suffix, e.g. duration_s.
+	// A serializer registered for time.Duration takes precedence, see RegisterSerializer.
+	DurationUnit DurationUnit
+
+	// RecentTraces optionally retains the most recent finished traces in memory, up to this many, for
+	// in process debugging, e.g. a "last N traces" debug endpoint. See Provider.RecentTraces.
+	// This is in addition to the normal export of spans, and is not subject to SampleTraces.
+	RecentTraces int
+
+	// BatchByTrace exports the spans of each trace together, bounded by a timeout (30s) and max spans (10000).
+	BatchByTrace         bool
+	BatchByTraceTimeout  time.Duration
+	BatchByTraceMaxSpans int
+
+	// Exporters are optional additional span exporters, e.g. for another backend (see the datadog package).
+	// Spans are sampled before they are exported, in the same way as for the other exporters.
+	Exporters []sdktrace.SpanExporter
+
+	// Propagators are optional additional propagators, e.g. for a proprietary trace header used by a legacy
+	// service. They are used alongside the default W3C trace context and baggage propagators, by the
+	// propagation helpers and so the HTTP and gRPC middleware. N.B. propagators are set globally.
+	Propagators []propagation.TextMapPropagator
 
 	// DisableText prevents output to stdout for noisy services. Ignored if no other no hosts are supplied
 	DisableText bool
 
 	Test bool
 
+	// SyncExport exports each span as it ends, rather than in batches, so that spans can be asserted
+	// on as soon as End returns. Test implies SyncExport. This is intended for tests and local
+	// development only, it is unsuitable for production since every End waits for the export.
+	SyncExport bool
+
 	Writer  io.Writer
 	Metrics o11y.ClosableMetricsProvider
 }
 
+const defaultMaxTraceFields = 100
+
 type Provider struct {
 	metricsProvider o11y.ClosableMetricsProvider
 	tracer          trace.Tracer
 	tp              *sdktrace.TracerProvider
+
+	requestIDs         bool
+	requestIDGenerator func() string
+
+	// crumbs is only set when debugging context propagation
+	crumbs *breadcrumbs
+	// collisions is only set when debugging field collisions
+	collisions io.Writer
+
+	exportErrors *exportErrors
+	redactor     *redactor
+	endHooks     []endHook
+	hookPanics   *atomic.Int64
+	inherited    map[string]bool
+
+	baggageFields []string
+	sampledFields map[string]uint
+	maxSpanDepth  int
+	serializers   *serializers
+	durationUnit  DurationUnit
+	logSampler    *logSampler
+	logDedup      int
+	nameValidator func(name string) error
+	recent        *recentTraces
+	sampler       *deterministicSampler
+	traceFieldCap int
+	parentIDField bool
+	cutShort      bool
+
+	// derived providers (see WithGlobalFields) add these global attributes, and do not own the tp
+	derivedGlobals []attribute.KeyValue
+	derived        bool
 }
 
 func New(conf Config) (o11y.Provider, error) {
 	var exporters []sdktrace.SpanExporter
 
 	if conf.GrpcHostAndPort != "" {
-		grpc, err := newGRPC(context.Background(), conf.GrpcHostAndPort, conf.Dataset)
+		grpc, err := newGRPC(context.Background(), conf.GrpcHostAndPort, conf.Dataset, conf.Compression, conf.Metrics)
 		if err != nil {
 			return nil, err
 		}
@@ -72,19 +281,24 @@ func New(conf Config) (o11y.Provider, error) {
 	}
 
 	if conf.HTTPTracesURL != "" {
-		http, err := newHTTP(context.Background(), conf.HTTPTracesURL, conf.Dataset, conf.HTTPAuthorization)
+		http, err := newHTTP(context.Background(), conf.HTTPTracesURL, conf.Dataset, conf.HTTPAuthorization,
+			conf.Compression)
 		if err != nil {
 			return nil, err
 		}
 
 		exporters = append(exporters, http)
 	}
+	exporters = append(exporters, conf.Exporters...)
 
 	var sampler *deterministicSampler
 	if conf.SampleTraces {
 		sampler = &deterministicSampler{
-			sampleKeyFunc: conf.SampleKeyFunc,
-			sampleRates:   conf.SampleRates,
+			sampleKeyFunc:   conf.SampleKeyFunc,
+			sampleRates:     conf.SampleRates,
+			sampleKeyFields: conf.SampleKeyFields,
+			byTraceID:       conf.SampleByTraceID,
+			seed:            conf.SampleSeed,
 		}
 	}
 
@@ -102,34 +316,133 @@ func New(conf Config) (o11y.Provider, error) {
 		exporters = append(exporters, text)
 	}
 
-	tp := traceProvider(multipleExporter{
+	var processors []sdktrace.SpanProcessor
+	if conf.AuditFile != "" {
+		audit, err := newAuditExporter(conf.AuditFile, conf.AuditFilter, conf.AuditMaxBytes)
+		if err != nil {
+			return nil, err
+		}
+		// the audit file is written synchronously, independent of sampling or errors in the main exporters
+		processors = append(processors, sdktrace.NewSimpleSpanProcessor(audit))
+	}
+
+	var recent *recentTraces
+	if conf.RecentTraces > 0 {
+		recent = newRecentTraces(conf.RecentTraces)
+		processors = append(processors, sdktrace.NewSimpleSpanProcessor(recent))
+	}
+
+	exportErrs := &exportErrors{onError: conf.OnExportError}
+	var exporter sdktrace.SpanExporter = multipleExporter{
 		exporters: exporters,
 		sampler:   sampler,
-	}, conf)
+		debug:     conf.SamplingDebug,
+		dryRun:    conf.SampleDryRun,
+		errs:      exportErrs,
+	}
+	if conf.BatchByTrace {
+		exporter = newTraceBatcher(exporter, conf.BatchByTraceTimeout, conf.BatchByTraceMaxSpans)
+	}
+	tp := traceProvider(exporter, conf, processors...)
 
 	// set the global options
 	otel.SetTracerProvider(tp)
-	propagator := propagation.NewCompositeTextMapPropagator(propagation.Baggage{}, propagation.TraceContext{})
+	propagators := append([]propagation.TextMapPropagator{propagation.Baggage{}, propagation.TraceContext{}},
+		conf.Propagators...)
+	propagator := propagation.NewCompositeTextMapPropagator(propagators...)
 	otel.SetTextMapPropagator(propagator)
 
 	// TODO check baggage is wired up above
 
+	requestIDGenerator := conf.RequestIDGenerator
+	if requestIDGenerator == nil {
+		requestIDGenerator = uuid.NewString
+	}
+
+	var crumbs *breadcrumbs
+	if os.Getenv(DebugPropagationEnv) != "" {
+		w := conf.Writer
+		if w == nil {
+			w = os.Stderr
+		}
+		crumbs = newBreadcrumbs(w)
+	}
+
+	var collisions io.Writer
+	if os.Getenv(DebugFieldCollisionsEnv) != "" {
+		collisions = conf.Writer
+		if collisions == nil {
+			collisions = os.Stderr
+		}
+	}
+
+	var inherited map[string]bool
+	if len(conf.InheritedFields) > 0 {
+		inherited = map[string]bool{}
+		for _, k := range conf.InheritedFields {
+			inherited[k] = true
+		}
+	}
+
+	maxTraceFields := conf.MaxTraceFields
+	if maxTraceFields <= 0 {
+		maxTraceFields = defaultMaxTraceFields
+	}
+
+	var hooks []endHook
+	if conf.TimingBreakdown {
+		hooks = append(hooks, timingBreakdownHook)
+	}
+	if conf.ChildCounts {
+		hooks = append(hooks, childCountHook)
+	}
+	if conf.CutShortFields {
+		hooks = append(hooks, cutShortHook)
+	}
+
 	return &Provider{
-		metricsProvider: conf.Metrics,
-		tp:              tp,
-		tracer:          otel.Tracer(""),
+		metricsProvider:    conf.Metrics,
+		tp:                 tp,
+		tracer:             otel.Tracer(""),
+		requestIDs:         conf.RequestIDs,
+		requestIDGenerator: requestIDGenerator,
+		crumbs:             crumbs,
+		collisions:         collisions,
+		exportErrors:       exportErrs,
+		redactor:           newRedactor(conf.RedactPatterns),
+		endHooks:           hooks,
+		hookPanics:         &atomic.Int64{},
+		inherited:          inherited,
+		baggageFields:      conf.BaggageFields,
+		sampledFields:      conf.SampledFields,
+		maxSpanDepth:       conf.MaxSpanDepth,
+		serializers:        newSerializers(conf.DurationUnit),
+		durationUnit:       conf.DurationUnit,
+		logSampler:         &logSampler{rate: uint64(conf.LogSampleRate)},
+		logDedup:           conf.LogDedupThreshold,
+		nameValidator:      conf.NameValidator,
+		recent:             recent,
+		sampler:            sampler,
+		traceFieldCap:      maxTraceFields,
+		parentIDField:      conf.ParentIDField,
+		cutShort:           conf.CutShortFields,
 	}, nil
 }
 
-func traceProvider(exporter sdktrace.SpanExporter, conf Config) *sdktrace.TracerProvider {
-	ra := append([]attribute.KeyValue{
+func traceProvider(exporter sdktrace.SpanExporter, conf Config,
+	processors ...sdktrace.SpanProcessor) *sdktrace.TracerProvider {
+	ra := []attribute.KeyValue{
 		attribute.String("x-honeycomb-dataset", conf.Dataset),
-	}, conf.ResourceAttributes...)
+	}
+	if !conf.DisableBuildInfo {
+		ra = append(ra, buildInfoAttributes()...)
+	}
+	ra = append(ra, conf.ResourceAttributes...)
 
 	res := resource.NewWithAttributes(semconv.SchemaURL, ra...)
 
 	var sp sdktrace.SpanProcessor
-	if conf.Test {
+	if conf.Test || conf.SyncExport {
 		sp = sdktrace.NewSimpleSpanProcessor(exporter)
 	} else {
 		sp = sdktrace.NewBatchSpanProcessor(exporter)
@@ -141,11 +454,28 @@ func traceProvider(exporter sdktrace.SpanExporter, conf Config) *sdktrace.Tracer
 		sdktrace.WithSpanProcessor(&globalFields),
 		sdktrace.WithResource(res),
 	}
+	for _, p := range processors {
+		traceOptions = append(traceOptions, sdktrace.WithSpanProcessor(p))
+	}
+	if conf.PropagateSampleRate {
+		traceOptions = append(traceOptions, sdktrace.WithSpanProcessor(sampleRateProcessor{}))
+	}
+	if conf.Sampler != nil {
+		var sampler sdktrace.Sampler = canarySampler{sampler: conf.Sampler}
+		if conf.PropagateSampleRate {
+			sampler = sampleRateSampler{sampler: sampler}
+		}
+		traceOptions = append(traceOptions, sdktrace.WithSampler(sampler))
+	}
+	if conf.IDGenerator != nil {
+		traceOptions = append(traceOptions, sdktrace.WithIDGenerator(conf.IDGenerator))
+	}
 
 	return sdktrace.NewTracerProvider(traceOptions...)
 }
 
-func newHTTP(ctx context.Context, endpoint, dataset string, token secret.String) (*otlptrace.Exporter, error) {
+func newHTTP(ctx context.Context, endpoint, dataset string, token secret.String,
+	compression Compression) (*otlptrace.Exporter, error) {
 	opts := []otlptracehttp.Option{
 		otlptracehttp.WithEndpointURL(endpoint),
 		// This header may be used by honeycomb ingestion pathways in the future, but
@@ -158,11 +488,15 @@ func newHTTP(ctx context.Context, endpoint, dataset string, token secret.String)
 			otlptracehttp.WithHeaders(map[string]string{"Authorization": fmt.Sprintf("Bearer %s", token.Raw())}),
 		)
 	}
+	if compression == CompressionGzip {
+		opts = append(opts, otlptracehttp.WithCompression(otlptracehttp.GzipCompression))
+	}
 
 	return otlptrace.New(ctx, otlptracehttp.NewClient(opts...))
 }
 
-func newGRPC(ctx context.Context, endpoint, dataset string) (*otlptrace.Exporter, error) {
+func newGRPC(ctx context.Context, endpoint, dataset string, compression Compression,
+	mp o11y.MetricsProvider) (*otlptrace.Exporter, error) {
 	opts := []otlptracegrpc.Option{
 		otlptracegrpc.WithEndpoint(endpoint),
 		otlptracegrpc.WithInsecure(),
@@ -171,6 +505,14 @@ func newGRPC(ctx context.Context, endpoint, dataset string) (*otlptrace.Exporter
 		// expect a resource attribute instead.
 		otlptracegrpc.WithHeaders(map[string]string{"x-honeycomb-dataset": dataset}),
 	}
+	if compression == CompressionGzip {
+		opts = append(opts, otlptracegrpc.WithCompressor(string(CompressionGzip)))
+	}
+	if mp != nil {
+		opts = append(opts, otlptracegrpc.WithDialOption(grpc.WithStatsHandler(newExportStats(mp, compression))))
+	}
+	// N.B. the client connects lazily, so an unreachable endpoint does not block or fail startup.
+	// Failures to export are reported via OnExportError and LastExportError instead.
 	return otlptrace.New(ctx, otlptracegrpc.NewClient(opts...))
 }
 
@@ -186,29 +528,155 @@ func (o Provider) AddGlobalField(key string, val any) {
 	globalFields.addField(key, val)
 }
 
+// WithGlobalFields returns a lightweight provider derived from this one, which shares its exporters and
+// configuration, but adds the extra global fields to its spans, overriding any global fields with the
+// same keys, e.g. for a plugin with its own service name. N.B. the extra fields are added just after
+// each span starts, so unlike AddGlobalField, they are not visible to head samplers. Closing the derived
+// provider does nothing, the original provider must still be closed.
+func (o Provider) WithGlobalFields(extra map[string]any) o11y.Provider {
+	keys := make([]string, 0, len(extra))
+	for k := range extra {
+		mustValidateKey(k)
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	globals := append([]attribute.KeyValue{}, o.derivedGlobals...)
+	for _, k := range keys {
+		globals = append(globals, attr(k, extra[k]))
+	}
+	o.derivedGlobals = globals
+	o.derived = true
+	return &o
+}
+
+// RegisterSerializer registers fn to convert any field values of type t (or pointers to t) as they are
+// added to spans, e.g. to give domain types such as money or ids their canonical compact representation.
+// The value returned by fn is then handled as any other field value.
+func (o Provider) RegisterSerializer(t reflect.Type, fn func(any) any) {
+	o.serializers.register(t, fn)
+}
+
 func (o Provider) StartSpan(ctx context.Context, name string, opts ...o11y.SpanOpt) (context.Context, o11y.Span) {
-	so := toOtelOpts(opts)
+	cfg := spanConfig(opts)
+	so := toOtelOpts(cfg)
+
+	parent := o.getSpan(ctx)
+	if cfg.NewRoot {
+		parent = nil
+	}
+	if parent != nil && o.maxSpanDepth > 0 && parent.depth >= o.maxSpanDepth {
+		return ctx, parent.truncatedChild()
+	}
+
+	var parentID string
+	if psc := trace.SpanContextFromContext(ctx); o.parentIDField && !cfg.NewRoot && psc.IsValid() {
+		parentID = psc.SpanID().String()
+	}
 
 	ctx, span := o.tracer.Start(ctx, name, so...)
+	if parentID != "" {
+		span.SetAttributes(attribute.String("trace.parent_id", parentID))
+	}
+	if len(o.derivedGlobals) > 0 {
+		span.SetAttributes(o.derivedGlobals...)
+	}
 
-	s := o.wrapSpan(name, opts, span, o.getSpan(ctx))
+	s := o.wrapSpan(name, opts, span, parent)
 	if s != nil {
+		if o.crumbs != nil {
+			s.crumbs = o.crumbs
+			s.gid = o.crumbs.started(name, parent == nil && !cfg.NewRoot)
+		}
+		// the start fields are already span attributes, but we also want them in the span fields
+		for _, f := range cfg.Fields {
+			s.AddRawField(f.Key, f.Value)
+		}
+		o.addBaggageFields(ctx, s)
+		if o.nameValidator != nil {
+			if err := o.nameValidator(name); err != nil {
+				s.AddRawField("name.invalid", true)
+				s.AddRawField("name.invalid_reason", err.Error())
+			}
+		}
+		// the span is still started so abandoned work can be recorded, but it is flagged
+		if ctx.Err() != nil {
+			s.AddRawField("started_cancelled", true)
+		}
+		if o.cutShort {
+			s.ctx = ctx
+		}
+		s.onEnd = cfg.OnEnd
 		ctx = context.WithValue(ctx, spanCtxKey{}, s)
+		ctx = o.addRequestID(ctx, s)
 	}
 
 	return ctx, s
 }
 
-func toOtelOpts(opts []o11y.SpanOpt) []trace.SpanStartOption {
+// addRequestID adds the request id from the context to the span, generating a new id if there is
+// none in the context yet, which will typically be as the root span is started.
+func (o Provider) addRequestID(ctx context.Context, s *span) context.Context {
+	if !o.requestIDs {
+		return ctx
+	}
+	id := o11y.RequestID(ctx)
+	if id == "" {
+		id = o.requestIDGenerator()
+		ctx = o11y.WithRequestID(ctx, id)
+	}
+	s.AddRawField("request.id", id)
+	return ctx
+}
+
+// addBaggageFields adds any of the configured baggage entries found in the context to the span.
+func (o Provider) addBaggageFields(ctx context.Context, s *span) {
+	if len(o.baggageFields) == 0 {
+		return
+	}
+	b := o11y.GetBaggage(ctx)
+	for _, k := range o.baggageFields {
+		if v, ok := b[k]; ok {
+			s.AddField(strings.ReplaceAll(k, "-", "_"), v)
+		}
+	}
+}
+
+func spanConfig(opts []o11y.SpanOpt) o11y.SpanConfig {
 	cfg := o11y.SpanConfig{}
 	for _, opt := range opts {
 		cfg = opt(cfg)
 	}
+	return cfg
+}
+
+func toOtelOpts(cfg o11y.SpanConfig) []trace.SpanStartOption {
 	if cfg.Kind == 0 {
 		cfg.Kind = o11y.SpanKindInternal
 	}
 	var so []trace.SpanStartOption
 	so = append(so, trace.WithSpanKind(trace.SpanKind(cfg.Kind)))
+	if len(cfg.Fields) > 0 {
+		attrs := make([]attribute.KeyValue, 0, len(cfg.Fields))
+		for _, f := range cfg.Fields {
+			if f.Value == nil {
+				continue
+			}
+			mustValidateKey(f.Key)
+			attrs = append(attrs, attr(f.Key, f.Value))
+		}
+		so = append(so, trace.WithAttributes(attrs...))
+	}
+	if cfg.NewRoot {
+		so = append(so, trace.WithNewRoot())
+	}
+	for _, l := range cfg.Links {
+		// ContextFrom validates and parses the span context, which is all that is needed of it here
+		sc := trace.SpanContextFromContext(o11y.ContextFrom(context.Background(), l))
+		if sc.IsValid() {
+			so = append(so, trace.WithLinks(trace.Link{SpanContext: sc}))
+		}
+	}
 	return so
 }
 
@@ -297,20 +765,104 @@ func (o Provider) AddFieldToTrace(ctx context.Context, key string, val any) {
 	}
 }
 
+// Log sends a zero duration span. The trace and span ids of the span it was logged in are added as the
+// trace_id and span_id fields, so the event can be joined back to that span in backends that query events
+// separately from spans. Outside any span, they are the ids of the event itself.
 func (o Provider) Log(ctx context.Context, name string, fields ...o11y.Pair) {
-	ctx, s := o.StartSpan(ctx, name)
+	if !o.logSampler.keep() {
+		if parent := o.getSpan(ctx); parent != nil {
+			parent.IncrTraceCounter("logs.sampled_out")
+		}
+		return
+	}
+
+	if parent := o.getSpan(ctx); parent != nil && o.logDedup > 0 {
+		emit := func(raw []o11y.Pair) {
+			o.log(context.WithoutCancel(ctx), name, raw, fields)
+		}
+		if !parent.logs.record(name, o.logDedup, emit) {
+			return
+		}
+	}
+	o.log(ctx, name, nil, fields)
+}
+
+func (o Provider) log(ctx context.Context, name string, raw, fields []o11y.Pair) {
+	_, s := o.StartSpan(ctx, name)
+	// the ids are the enclosing span's, which is what the event should be joined to, since the event's
+	// own span id is that of the zero duration span
+	sc := s.Context()
+	if parent := o.getSpan(ctx); parent != nil {
+		sc = parent.Context()
+	}
+	if sc.IsValid() {
+		s.AddRawField("trace_id", sc.TraceID)
+		s.AddRawField("span_id", sc.SpanID)
+	}
+	for _, f := range raw {
+		s.AddRawField(f.Key, f.Value)
+	}
 	for _, f := range fields {
 		s.AddField(f.Key, f.Value)
 	}
 	s.End()
 }
 
+// Close flushes and shuts down the trace provider and then the metrics provider. Span metrics are
+// sent as spans end, so the metrics provider must be closed last, to flush the final metrics.
+// Any errors are reported via Config.OnExportError and LastExportError.
 func (o Provider) Close(ctx context.Context) {
-	// TODO Handle these errors in a sensible manner where possible
-	_ = o.tp.Shutdown(ctx)
+	if o.derived {
+		return
+	}
+	errs := []error{o.tp.Shutdown(ctx)}
 	if o.metricsProvider != nil {
-		_ = o.metricsProvider.Close()
+		errs = append(errs, o.metricsProvider.Close())
+	}
+	if err := errors.Join(errs...); err != nil {
+		o.exportErrors.record(fmt.Errorf("close: %w", err))
+	}
+}
+
+// EndHookPanics returns the number of panics recovered from the hooks run as spans end, e.g. for
+// TimingBreakdown, which would otherwise have been lost.
+func (o Provider) EndHookPanics() int64 {
+	if o.hookPanics == nil {
+		return 0
+	}
+	return o.hookPanics.Load()
+}
+
+// LastExportError returns the error from the most recent attempt to export spans, or nil if it succeeded
+// or there have been no exports yet. This can be used for readiness checks or alerting on exporter failures.
+func (o Provider) LastExportError() error {
+	return o.exportErrors.lastError()
+}
+
+// SampleRates returns a copy of the SampleTraces sample rates currently in effect, e.g. for display on
+// an admin endpoint. It returns nil if SampleTraces is not set.
+func (o Provider) SampleRates() map[string]uint {
+	if o.sampler == nil {
+		return nil
 	}
+	return o.sampler.rates()
+}
+
+// UpdateSampleRates replaces the SampleTraces sample rates, taking effect for spans exported from now on.
+// The rates are copied, so the caller may reuse the map. It does nothing if SampleTraces is not set.
+func (o Provider) UpdateSampleRates(rates map[string]uint) {
+	if o.sampler == nil {
+		return
+	}
+	o.sampler.updateRates(rates)
+}
+
+// RecentTraces returns the most recent finished traces, most recent first, if Config.RecentTraces is set.
+func (o Provider) RecentTraces() []Trace {
+	if o.recent == nil {
+		return nil
+	}
+	return o.recent.traces()
 }
 
 func (o Provider) MetricsProvider() o11y.MetricsProvider {
@@ -337,27 +889,55 @@ func (o Provider) wrapSpan(name string, opts []o11y.SpanOpt, s trace.Span, p *sp
 		name:            name,
 		opts:            opts,
 		metricsProvider: o.metricsProvider,
+		redactor:        o.redactor,
+		endHooks:        o.endHooks,
+		hookPanics:      o.hookPanics,
+		sampledFields:   o.sampledFields,
+		serializers:     o.serializers,
+		durationUnit:    o.durationUnit,
+		collisions:      o.collisions,
 		parent:          p,
 		span:            s,
 		start:           time.Now(),
 		fields:          map[string]any{},
 	}
 	if p == nil {
+		sp.depth = 1
 		sp.tr = &tr{
-			fields: map[string]any{},
+			fields:    map[string]any{},
+			inherited: o.inherited,
+			maxFields: o.traceFieldCap,
 		}
 	} else {
+		sp.depth = p.depth + 1
 		sp.tr = p.tr
+		p.children.Add(1)
 		if p.flattenPrefix != "" {
 			sp.flatten("", 0)
 		}
 	}
+	sp.tr.spans.Add(1)
 	return sp
 }
 
 type tr struct {
 	mu     sync.RWMutex // mu is a write mutex for the map below (concurrent reads are safe)
 	fields map[string]any
+	// inherited if set restricts the fields above that are added to child spans
+	inherited map[string]bool
+	// maxFields caps the number of distinct fields above
+	maxFields int
+
+	errMu    sync.Mutex
+	errCount int
+	errFirst string
+
+	countMu  sync.Mutex
+	counters map[string]int
+
+	timings timings
+	// spans counts all the spans started in the trace
+	spans atomic.Int64
 }
 
 func (t *tr) addField(key string, val any) {
@@ -371,8 +951,54 @@ func (t *tr) addField(key string, val any) {
 	mustValidateKey(key)
 
 	t.mu.Lock()
-	t.fields[key] = val
+	_, exists := t.fields[key]
+	drop := !exists && t.maxFields > 0 && len(t.fields) >= t.maxFields
+	if !drop {
+		t.fields[key] = val
+	}
 	t.mu.Unlock()
+
+	if drop {
+		t.incrCounter("trace_fields.dropped")
+	}
+}
+
+func (t *tr) recordError(err error) {
+	t.errMu.Lock()
+	defer t.errMu.Unlock()
+
+	if t.errCount == 0 {
+		t.errFirst = err.Error()
+	}
+	t.errCount++
+}
+
+func (t *tr) errorSummary() (count int, first string) {
+	t.errMu.Lock()
+	defer t.errMu.Unlock()
+
+	return t.errCount, t.errFirst
+}
+
+func (t *tr) incrCounter(name string) {
+	t.countMu.Lock()
+	defer t.countMu.Unlock()
+
+	if t.counters == nil {
+		t.counters = map[string]int{}
+	}
+	t.counters[name]++
+}
+
+func (t *tr) counterSummary() map[string]int {
+	t.countMu.Lock()
+	defer t.countMu.Unlock()
+
+	counters := make(map[string]int, len(t.counters))
+	for k, v := range t.counters {
+		counters[k] = v
+	}
+	return counters
 }
 
 type span struct {
@@ -384,7 +1010,27 @@ type span struct {
 	span            trace.Span
 	metrics         []o11y.Metric
 	metricsProvider o11y.ClosableMetricsProvider
+	redactor        *redactor
+	endHooks        []endHook
+	onEnd           []func(o11y.Span)
+	hookPanics      *atomic.Int64
+	sampledFields   map[string]uint
+	serializers     *serializers
+	durationUnit    DurationUnit
 	start           time.Time
+	duration        time.Duration
+	depth           int
+	truncated       atomic.Int64
+	children        atomic.Int64
+	logs            collapsedLogs
+
+	// crumbs and gid are used for debugging context propagation
+	crumbs *breadcrumbs
+	gid    uint64
+	// collisions is used for debugging field collisions
+	collisions io.Writer
+	// ctx is the context the span was started with, only kept for CutShortFields
+	ctx context.Context
 
 	// name and opts are needed to be able to create a matching golden span
 	name string
@@ -424,8 +1070,16 @@ func (s *span) AddRawField(key string, val any) {
 		return
 	}
 	mustValidateKey(key)
+	if !s.keepField(key) {
+		return
+	}
+	val = s.serializers.serialize(val)
+	val = s.redactor.redact(val)
 
 	s.mu.Lock()
+	if s.collisions != nil {
+		s.checkCollision(key, val)
+	}
 	s.fields[key] = val
 
 	if err, ok := val.(error); ok {
@@ -444,27 +1098,183 @@ func (s *span) AddRawField(key string, val any) {
 	s.span.SetAttributes(attr(key, val))
 }
 
+// AddToField atomically adds delta to an integer valued field, which starts from zero.
+func (s *span) AddToField(key string, delta int64) {
+	if s == nil {
+		return
+	}
+	mustValidateKey(key)
+	if !s.keepField(key) {
+		return
+	}
+
+	s.mu.Lock()
+	n, _ := s.fields[key].(int64)
+	n += delta
+	s.fields[key] = n
+	// set the attribute under the lock, so concurrent updates are applied in order
+	s.span.SetAttributes(attr(key, n))
+	s.mu.Unlock()
+}
+
+// GetField returns the value of a field that has been added to the span.
+func (s *span) GetField(key string) (any, bool) {
+	if s == nil {
+		return nil, false
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	val, ok := s.fields[key]
+	return val, ok
+}
+
+// AppendField adds val to a list valued field. If the key already holds a single value
+// that value becomes the first element of the list.
+func (s *span) AppendField(key string, val any) {
+	if s == nil {
+		return
+	}
+	// chuck out nil values
+	if val == nil {
+		return
+	}
+	key = "app." + key
+	mustValidateKey(key)
+	if !s.keepField(key) {
+		return
+	}
+
+	val = s.serializers.serialize(val)
+	val = s.redactor.redact(val)
+	if err, ok := val.(error); ok {
+		val = err.Error()
+	}
+
+	s.mu.Lock()
+	var list []any
+	switch v := s.fields[key].(type) {
+	case nil:
+	case []any:
+		// copy, so previous snapshots of the fields are not mutated
+		list = make([]any, 0, len(v)+1)
+		list = append(list, v...)
+	default:
+		list = []any{v}
+	}
+	list = append(list, val)
+	s.fields[key] = list
+	s.mu.Unlock()
+
+	s.span.SetAttributes(attr(key, list))
+}
+
+// SetStatus sets the otel span status.
+func (s *span) SetStatus(code o11y.StatusCode, description string) {
+	switch code {
+	case o11y.StatusOK:
+		s.span.SetStatus(codes.Ok, "")
+	case o11y.StatusError:
+		s.span.SetStatus(codes.Error, description)
+	default:
+		s.span.SetStatus(codes.Unset, "")
+	}
+}
+
+func (s *span) StartTime() time.Time {
+	return s.start
+}
+
+func (s *span) Context() o11y.SpanContext {
+	sc := s.span.SpanContext()
+	if !sc.IsValid() {
+		return o11y.SpanContext{}
+	}
+	return o11y.SpanContext{
+		TraceID:    sc.TraceID().String(),
+		SpanID:     sc.SpanID().String(),
+		Sampled:    sc.IsSampled(),
+		TraceState: sc.TraceState().String(),
+	}
+}
+
+// IncrTraceCounter counts name against the trace, the totals are added to the (local) root span when it ends.
+func (s *span) IncrTraceCounter(name string) {
+	if s.tr == nil {
+		return
+	}
+	mustValidateKey(name)
+	s.tr.incrCounter(name)
+}
+
+// truncatedChild counts a child span that was not started because the max span depth was reached,
+// and returns this span in its place.
+func (s *span) truncatedChild() o11y.Span {
+	s.AddRawField("depth.truncated", s.truncated.Add(1))
+	return truncatedSpan{span: s}
+}
+
+// truncatedSpan stands in for a span that was not started because the max span depth was reached.
+// Fields are added to the parent span, but ending it does not end the parent.
+type truncatedSpan struct {
+	*span
+}
+
+func (truncatedSpan) End() {}
+
+// IsRecording reports whether the span will be exported, as decided by any head sampler.
+func (s *span) IsRecording() bool {
+	return s.span.IsRecording()
+}
+
+// RecordError records err as an event on the span, and counts it against the trace, so that the
+// (local) root span can summarise the errors in the trace when it ends.
+func (s *span) RecordError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.span.RecordError(err)
+	if s.tr != nil {
+		s.tr.recordError(err)
+	}
+}
+
 // RecordMetric will only emit a metric if End is called specifically
 func (s *span) RecordMetric(metric o11y.Metric) {
 	s.metrics = append(s.metrics, metric)
 }
 
 func (s *span) End() {
-	// insert the expected field for any timing metric
+	// insert the duration field, in the configured unit, e.g. duration_ms
 	s.mu.Lock()
-	s.fields["duration_ms"] = time.Since(s.start).Milliseconds()
+	s.duration = time.Since(s.start)
+	s.fields[durationField(s.durationUnit)] = s.durationUnit.value(s.duration)
 	s.mu.Unlock()
 
 	if s.tr != nil {
 		s.tr.mu.RLock()
 		for k, v := range s.tr.fields {
+			if s.parent != nil && s.tr.inherited != nil && !s.tr.inherited[k] {
+				continue
+			}
 			s.AddField(k, v)
 		}
 		s.tr.mu.RUnlock()
 	}
 
+	s.logs.flush()
+	s.runEndHooks()
+
+	if s.parent == nil {
+		s.addTraceSummary()
+	}
+
 	s.sendMetric()
 
+	if s.crumbs != nil {
+		s.crumbs.ended(s.gid)
+	}
+
 	// If this span was asked to be flattened, add its fields to the parent, and don't end the span
 	if s.flattenPrefix != "" {
 		if s.parent != nil {
@@ -483,6 +1293,20 @@ func (s *span) End() {
 	}
 }
 
+// addTraceSummary adds the fields that summarise the whole trace to the (local) root span.
+func (s *span) addTraceSummary() {
+	if s.tr == nil {
+		return
+	}
+	if count, first := s.tr.errorSummary(); count > 0 {
+		s.AddRawField("errors.count", count)
+		s.AddRawField("errors.first", first)
+	}
+	for name, count := range s.tr.counterSummary() {
+		s.AddRawField(name, count)
+	}
+}
+
 // copy span attributes into s
 func (s *span) copyAttrsFrom(span *span) {
 	// get the span name
@@ -499,7 +1323,7 @@ func (s *span) copyAttrsFrom(span *span) {
 	s.name = spName
 	s.span.SetName(spName)
 	for k, v := range attrs {
-		if k == "name" || k == "duration_ms" {
+		if k == "name" || k == durationField(span.durationUnit) {
 			continue
 		}
 		s.fields[k] = v
@@ -529,7 +1353,21 @@ func (s *span) sendMetric() {
 	if s.metricsProvider == nil {
 		return
 	}
-	extractAndSendMetrics(s.metricsProvider)(s.metrics, s.snapshotFields())
+	fields := s.snapshotFields()
+	// Timing metrics expect duration_ms, which is given the span duration whatever the unit of the
+	// duration field is
+	if _, ok := fields["duration_ms"]; !ok {
+		fields["duration_ms"] = s.duration
+	}
+	extractAndSendMetrics(s.metricsProvider)(s.metrics, fields)
+}
+
+// FieldsSnapshot returns a copy of the span fields, see o11y.FieldsSnapshot.
+func (s *span) FieldsSnapshot() map[string]any {
+	if s == nil {
+		return nil
+	}
+	return s.snapshotFields()
 }
 
 func (s *span) snapshotFields() map[string]any {
@@ -552,16 +1390,23 @@ func mustValidateKey(key string) {
 type multipleExporter struct {
 	exporters []sdktrace.SpanExporter
 	sampler   *deterministicSampler
+	debug     bool
+	dryRun    bool
+	errs      *exportErrors
 }
 
 func (m multipleExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
 	spans = m.sampleSpans(spans)
+	var errs []error
 	for _, e := range m.exporters {
+		// keep going, so one failing exporter does not prevent the others from exporting
 		if err := e.ExportSpans(ctx, spans); err != nil {
-			return err
+			errs = append(errs, err)
 		}
 	}
-	return nil
+	err := errors.Join(errs...)
+	m.errs.record(err)
+	return err
 }
 
 func (m multipleExporter) Shutdown(ctx context.Context) error {
@@ -579,19 +1424,63 @@ func (m multipleExporter) sampleSpans(spans []sdktrace.ReadOnlySpan) []sdktrace.
 	}
 	ss := make([]sdktrace.ReadOnlySpan, 0, len(spans))
 	for _, s := range spans {
-		if ok, rate := m.sampler.shouldSample(s); ok {
-			ss = append(ss, sampleRateSpan{ReadOnlySpan: s, rate: rate})
+		ok, rate := m.sampler.shouldSample(s)
+		rs := sampleRateSpan{ReadOnlySpan: s, rate: rate}
+		if m.dryRun {
+			rs.rate = 1
+			if !ok {
+				rs.extra = append(rs.extra, attribute.Bool("sampling.would_drop", true))
+			}
+			ok = true
+		}
+		if !ok {
+			continue
 		}
+		if m.debug {
+			rs.extra = append(rs.extra, m.sampler.determinantAttr(s))
+		}
+		ss = append(ss, rs)
 	}
 	return ss
 }
 
+// exportErrors keeps track of the last export error, and notifies the optional callback of errors
+type exportErrors struct {
+	onError func(error)
+
+	mu   sync.RWMutex
+	last error
+}
+
+func (e *exportErrors) record(err error) {
+	if e == nil {
+		return
+	}
+	e.mu.Lock()
+	e.last = err
+	e.mu.Unlock()
+
+	if err != nil && e.onError != nil {
+		e.onError(err)
+	}
+}
+
+func (e *exportErrors) lastError() error {
+	if e == nil {
+		return nil
+	}
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.last
+}
+
 type sampleRateSpan struct {
 	sdktrace.ReadOnlySpan
-	rate uint
+	rate  uint
+	extra []attribute.KeyValue
 }
 
 func (s sampleRateSpan) Attributes() []attribute.KeyValue {
 	rate := int(s.rate) //nolint:gosec
-	return append(s.ReadOnlySpan.Attributes(), attribute.Int("SampleRate", rate))
+	return append(append(s.ReadOnlySpan.Attributes(), attribute.Int("SampleRate", rate)), s.extra...)
 }
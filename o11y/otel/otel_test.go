@@ -2,6 +2,8 @@ package otel_test
 
 import (
 	"context"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -9,6 +11,10 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"runtime"
 	"sort"
 	"strconv"
 	"strings"
@@ -16,8 +22,13 @@ import (
 	"testing"
 	"time"
 
+	"github.com/DataDog/datadog-go/statsd"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
 	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
 	v1 "go.opentelemetry.io/proto/otlp/common/v1"
 	"golang.org/x/sync/errgroup"
@@ -33,6 +44,7 @@ import (
 	"github.com/circleci/ex/internal/syncbuffer"
 	"github.com/circleci/ex/o11y"
 	"github.com/circleci/ex/o11y/otel"
+	"github.com/circleci/ex/testing/fakemetrics"
 	"github.com/circleci/ex/testing/fakestatsd"
 	"github.com/circleci/ex/testing/httprecorder"
 	"github.com/circleci/ex/testing/httprecorder/ginrecorder"
@@ -292,6 +304,117 @@ func TestConcurrentSpanAccess(t *testing.T) {
 	})
 }
 
+func TestUnreachableEndpoint(t *testing.T) {
+	// nothing is listening here
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NilError(t, err)
+	addr := lis.Addr().String()
+	assert.NilError(t, lis.Close())
+
+	start := time.Now()
+	op, err := otel.New(otel.Config{
+		GrpcHostAndPort: addr,
+		DisableText:     true,
+	})
+	assert.NilError(t, err)
+	assert.Check(t, time.Since(start) < time.Second, "startup should not wait for the endpoint")
+
+	ctx := o11y.WithProvider(context.Background(), op)
+	_, span := o11y.StartSpan(ctx, "span")
+	span.End()
+
+	closeCtx, cancel := context.WithTimeout(ctx, 500*time.Millisecond)
+	defer cancel()
+	op.Close(closeCtx)
+
+	assert.Check(t, op.(*otel.Provider).LastExportError() != nil)
+}
+
+func TestAuditFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "audit.log")
+
+	op, err := otel.New(otel.Config{
+		Writer:    &syncbuffer.SyncBuffer{},
+		AuditFile: path,
+		AuditFilter: func(s otel.SpanSnapshot) bool {
+			return s.Name == "audited"
+		},
+		AuditMaxBytes: 4096,
+	})
+	assert.NilError(t, err)
+	ctx := o11y.WithProvider(context.Background(), op)
+
+	g := &errgroup.Group{}
+	for n := 0; n < 100; n++ {
+		n := n
+		g.Go(func() error {
+			_, span := o11y.StartSpan(ctx, "audited")
+			span.AddField("n", n)
+			span.End()
+			_, span = o11y.StartSpan(ctx, "ignored")
+			span.End()
+			return nil
+		})
+	}
+	assert.Check(t, g.Wait())
+	op.Close(ctx)
+
+	files, err := filepath.Glob(path + "*")
+	assert.NilError(t, err)
+	assert.Check(t, len(files) > 1, "expected the audit file to be rotated")
+
+	seen := map[int]bool{}
+	for _, f := range files {
+		b, err := os.ReadFile(f)
+		assert.NilError(t, err)
+		assert.Check(t, len(b) <= 4096)
+		for _, line := range strings.Split(strings.TrimSpace(string(b)), "\n") {
+			var s otel.SpanSnapshot
+			assert.NilError(t, json.Unmarshal([]byte(line), &s))
+			assert.Check(t, cmp.Equal(s.Name, "audited"))
+			seen[int(s.Fields["app.n"].(float64))] = true
+		}
+	}
+	assert.Check(t, cmp.Len(seen, 100))
+}
+
+func TestCloseFlushesMetrics(t *testing.T) {
+	s := fakestatsd.New(t)
+	// flush and aggregation intervals far longer than the test, so only Close will send the metrics
+	stats, err := statsd.New(s.Addr(),
+		statsd.WithBufferFlushInterval(time.Hour),
+		statsd.WithAggregationInterval(time.Hour),
+	)
+	assert.NilError(t, err)
+
+	var closeErr error
+	op, err := otel.New(otel.Config{
+		Writer:        &syncbuffer.SyncBuffer{},
+		Metrics:       stats,
+		OnExportError: func(err error) { closeErr = err },
+	})
+	assert.NilError(t, err)
+	ctx := o11y.WithProvider(context.Background(), op)
+
+	_, span := o11y.StartSpan(ctx, "job")
+	span.RecordMetric(o11y.Incr("jobs"))
+	span.End()
+	assert.Check(t, cmp.Len(s.Metrics(), 0))
+
+	op.Close(ctx)
+	assert.Check(t, closeErr)
+
+	poll.WaitOn(t, func(t poll.LogT) poll.Result {
+		for _, m := range s.Metrics() {
+			if m.Name == "jobs" {
+				return poll.Success()
+			}
+		}
+		return poll.Continue("metric never turned up")
+	})
+}
+
 func TestFailureMetrics(t *testing.T) {
 	s := fakestatsd.New(t)
 	ctx, closeProvider, err := o11yconfig.Otel(context.Background(), o11yconfig.OtelConfig{
@@ -585,6 +708,178 @@ func TestSampling(t *testing.T) {
 	})
 }
 
+func TestRatioSampler(t *testing.T) {
+	col, addr := startTestCollector(t)
+
+	prov, err := otel.New(otel.Config{
+		Dataset:         "execyooshun",
+		GrpcHostAndPort: addr,
+		Sampler:         otel.NewRatioSampler(0.5),
+	})
+	assert.NilError(t, err)
+	ctx := o11y.WithProvider(context.Background(), prov)
+	for n := 0; n < 100; n++ {
+		_, span := prov.StartSpan(ctx, "span-name")
+		span.End()
+	}
+	prov.Close(ctx)
+
+	spans := col.Spans()
+	assert.Check(t, len(spans) > 10 && len(spans) < 90, "unexpected number of spans: %d", len(spans))
+	for _, s := range spans {
+		assert.Check(t, cmp.Equal(s.Attrs["SampleRate"], "2"))
+	}
+}
+
+func TestAddFieldFunc_NotRecording(t *testing.T) {
+	prov, err := otel.New(otel.Config{
+		Writer:  &syncbuffer.SyncBuffer{},
+		Sampler: sdktrace.NeverSample(),
+	})
+	assert.NilError(t, err)
+	ctx := o11y.WithProvider(context.Background(), prov)
+	defer prov.Close(ctx)
+
+	ctx, span := o11y.StartSpan(ctx, "dropped")
+	defer span.End()
+
+	o11y.AddFieldFunc(ctx, "expensive", func() any {
+		t.Error("should not compute fields for unsampled spans")
+		return nil
+	})
+}
+
+func TestIDs_NotRecording(t *testing.T) {
+	prov, err := otel.New(otel.Config{
+		Writer:  &syncbuffer.SyncBuffer{},
+		Sampler: sdktrace.NeverSample(),
+	})
+	assert.NilError(t, err)
+	ctx := o11y.WithProvider(context.Background(), prov)
+	defer prov.Close(ctx)
+
+	ctx, root := o11y.StartSpan(ctx, "dropped")
+	defer root.End()
+	_, child := o11y.StartSpan(ctx, "dropped child")
+	defer child.End()
+
+	// unsampled spans still have ids, for correlating logs with a trace
+	rsc := root.Context()
+	assert.Check(t, cmp.Regexp(`^[0-9a-f]{32}$`, rsc.TraceID))
+	assert.Check(t, cmp.Regexp(`^[0-9a-f]{16}$`, rsc.SpanID))
+	assert.Check(t, !rsc.Sampled)
+
+	csc := child.Context()
+	assert.Check(t, cmp.Equal(csc.TraceID, rsc.TraceID))
+	assert.Check(t, csc.SpanID != rsc.SpanID)
+
+	traceID, _ := prov.Helpers().TraceIDs(ctx)
+	assert.Check(t, cmp.Equal(traceID, rsc.TraceID))
+}
+
+func TestSyncExport(t *testing.T) {
+	buf := &syncbuffer.SyncBuffer{}
+	prov, err := otel.New(otel.Config{Writer: buf, SyncExport: true})
+	assert.NilError(t, err)
+	ctx := o11y.WithProvider(context.Background(), prov)
+	defer prov.Close(ctx)
+
+	_, span := o11y.StartSpan(ctx, "sync-span")
+	span.AddField("key", "value")
+	span.End()
+
+	// exported before End returns, without waiting for a batch or closing the provider
+	assert.Check(t, cmp.Contains(buf.String(), "sync-span app.key=value"))
+}
+
+func TestTraceIDFromError(t *testing.T) {
+	prov, err := otel.New(otel.Config{Writer: &syncbuffer.SyncBuffer{}})
+	assert.NilError(t, err)
+	ctx := o11y.WithProvider(context.Background(), prov)
+	defer prov.Close(ctx)
+
+	ctx, root := o11y.StartSpan(ctx, "root")
+	defer root.End()
+
+	assert.Check(t, o11y.NewError(ctx, nil) == nil)
+
+	sentinel := errors.New("boom")
+	wrapped := o11y.NewError(ctx, sentinel)
+	assert.Check(t, cmp.Error(wrapped, "boom"))
+	assert.Check(t, errors.Is(wrapped, sentinel))
+
+	// the originating span is kept when wrapped again further up the stack
+	_, child := o11y.StartSpan(ctx, "child")
+	defer child.End()
+	outer := fmt.Errorf("outer: %w", wrapped)
+	assert.Check(t, errors.Is(o11y.NewError(ctx, outer), outer))
+
+	traceID, ok := o11y.TraceIDFromError(outer)
+	assert.Check(t, ok)
+	assert.Check(t, cmp.Equal(traceID, root.Context().TraceID))
+
+	sc, ok := o11y.SpanContextFromError(outer)
+	assert.Check(t, ok)
+	assert.Check(t, cmp.Equal(sc.SpanID, root.Context().SpanID))
+
+	var e *o11y.Error
+	assert.Check(t, errors.As(outer, &e))
+
+	_, ok = o11y.TraceIDFromError(sentinel)
+	assert.Check(t, !ok)
+
+	// without an active span the error is returned unchanged
+	assert.Check(t, o11y.NewError(context.Background(), sentinel) == sentinel) //nolint:errorlint
+}
+
+func TestThresholdSampler(t *testing.T) {
+	col, addr := startTestCollector(t)
+
+	prov, err := otel.New(otel.Config{
+		Dataset:         "execyooshun",
+		GrpcHostAndPort: addr,
+		Sampler: otel.NewThresholdSampler(1e6,
+			otel.ThresholdRule{Field: "content_length", Op: otel.GreaterThan, Value: 1000, Rate: 1},
+		),
+	})
+	assert.NilError(t, err)
+	ctx := o11y.WithProvider(context.Background(), prov)
+	for n := 0; n < 20; n++ {
+		_, span := prov.StartSpan(ctx, "large", o11y.WithStartFields(o11y.Field("content_length", 2000)))
+		span.End()
+		_, span = prov.StartSpan(ctx, "small", o11y.WithStartFields(o11y.Field("content_length", 10)))
+		span.End()
+	}
+	prov.Close(ctx)
+
+	spans := col.Spans()
+	assert.Check(t, cmp.Len(spans, 20))
+	for _, s := range spans {
+		assert.Check(t, cmp.Equal(s.Name, "large"))
+		assert.Check(t, cmp.Equal(s.Attrs["SampleRate"], "1"))
+		assert.Check(t, cmp.Equal(s.Attrs["content_length"], "2000"))
+	}
+}
+
+// startTestCollector starts a grpc trace collector that is stopped when the test completes,
+// returning the collector and the address to send traces to.
+func startTestCollector(t *testing.T) (*testTraceCollector, string) {
+	t.Helper()
+	col := &testTraceCollector{}
+
+	lis, err := net.Listen("tcp", "localhost:0")
+	assert.Assert(t, err)
+
+	grpcServer := grpc.NewServer()
+	coltracepb.RegisterTraceServiceServer(grpcServer, col)
+	t.Cleanup(grpcServer.Stop)
+
+	go func() {
+		_ = grpcServer.Serve(lis)
+	}()
+	return col, lis.Addr().String()
+}
+
 func TestKind(t *testing.T) {
 	var (
 		srvURL              string
@@ -945,6 +1240,8 @@ type CollectSpan struct {
 	Kind   string
 	Attrs  map[string]string
 	Events []CollectEvent
+	// Links are the linked span contexts, as trace_id:span_id
+	Links []string
 }
 
 type CollectEvent struct {
@@ -1017,6 +1314,10 @@ func (c *testTraceCollector) Export(ctx context.Context,
 					}
 					cspan.Events = append(cspan.Events, ce)
 				}
+				for _, link := range span.GetLinks() {
+					cspan.Links = append(cspan.Links,
+						hex.EncodeToString(link.GetTraceId())+":"+hex.EncodeToString(link.GetSpanId()))
+				}
 				c.spans = append(c.spans, cspan)
 			}
 		}
@@ -1048,117 +1349,1428 @@ func TestOtel_Writer(t *testing.T) {
 	assert.Check(t, cmp.Contains(b.String(), "a span"))
 }
 
-func newOtelCollector(recorder *httprecorder.RequestRecorder) http.Handler {
-	ctx := testcontext.Background()
-	r := ginrouter.Default(ctx, "fake-otel-collector")
-	r.Use(ginrecorder.Middleware(ctx, recorder))
+func TestSpan_AppendField(t *testing.T) {
+	var b syncbuffer.SyncBuffer
+	op, err := otel.New(otel.Config{
+		Writer: &b,
+	})
+	assert.NilError(t, err)
+	ctx := o11y.WithProvider(context.Background(), op)
 
-	r.POST("/v1/traces", func(c *gin.Context) {
-		c.Status(http.StatusOK)
+	_, span := o11y.StartSpan(ctx, "a span")
+	span.AppendField("issues", "first")
+	span.AppendField("issues", errors.New("second"))
+
+	span.AddField("scalar", 1)
+	span.AppendField("scalar", 2)
+	span.End()
+	op.Close(ctx)
+
+	assert.Check(t, cmp.Contains(b.String(), `app.issues=["first","second"]`))
+	assert.Check(t, cmp.Contains(b.String(), `app.scalar=["1","2"]`))
+}
+
+func TestRecordError(t *testing.T) {
+	var b syncbuffer.SyncBuffer
+	op, err := otel.New(otel.Config{
+		Writer: &b,
 	})
-	return r
+	assert.NilError(t, err)
+	ctx := o11y.WithProvider(context.Background(), op)
+
+	ctx, root := o11y.StartSpan(ctx, "root")
+	func() {
+		ctx, span := o11y.StartSpan(ctx, "child")
+		defer span.End()
+		o11y.RecordError(ctx, errors.New("first problem"))
+		o11y.RecordError(ctx, nil)
+	}()
+	o11y.RecordError(ctx, errors.New("second problem"))
+	root.End()
+	op.Close(ctx)
+
+	assert.Check(t, cmp.Contains(b.String(), "root errors.count=2 errors.first=first problem"))
 }
 
-func TestMetrics(t *testing.T) {
-	ctx := context.Background()
-	s := fakestatsd.New(t)
-	ns := "test"
-	ctx, closeProvider, err := o11yconfig.Otel(context.Background(), o11yconfig.OtelConfig{
-		Service:        "app-main",
-		Version:        "dev-test",
-		Statsd:         s.Addr(),
-		StatsNamespace: ns,
+func TestRequestIDs(t *testing.T) {
+	var b syncbuffer.SyncBuffer
+	op, err := otel.New(otel.Config{
+		Writer:     &b,
+		RequestIDs: true,
+		RequestIDGenerator: func() string {
+			return "generated_id"
+		},
 	})
 	assert.NilError(t, err)
-	defer closeProvider(ctx)
+	ctx := o11y.WithProvider(context.Background(), op)
 
-	_, span := o11y.StartSpan(ctx, "test")
-	span.RecordMetric(o11y.Timing("timing"))
+	t.Run("generated", func(t *testing.T) {
+		ctx, root := o11y.StartSpan(ctx, "root")
+		assert.Check(t, cmp.Equal(o11y.RequestID(ctx), "generated_id"))
 
-	span.AddField("count", 22)
-	span.RecordMetric(o11y.Count("count", "count", nil))
+		ctx, child := o11y.StartSpan(ctx, "child")
+		assert.Check(t, cmp.Equal(o11y.RequestID(ctx), "generated_id"))
+		child.End()
+		root.End()
+	})
 
-	span.AddField("custom_duration", 10000)
-	span.RecordMetric(o11y.Duration("duration", "custom_duration"))
+	t.Run("incoming", func(t *testing.T) {
+		ctx := o11y.WithRequestID(ctx, "incoming_id")
+		ctx, root := o11y.StartSpan(ctx, "incoming root")
+		assert.Check(t, cmp.Equal(o11y.RequestID(ctx), "incoming_id"))
+		root.End()
+	})
 
-	span.RecordMetric(o11y.Incr("counter"))
+	op.Close(ctx)
+	assert.Check(t, cmp.Contains(b.String(), "root request.id=generated_id"))
+	assert.Check(t, cmp.Contains(b.String(), "child request.id=generated_id"))
+	assert.Check(t, cmp.Contains(b.String(), "incoming root request.id=incoming_id"))
+}
 
-	span.AddRawField("gauge", 34)
-	span.RecordMetric(o11y.Gauge("gauge", "gauge"))
+func TestDebugPropagation(t *testing.T) {
+	t.Setenv(otel.DebugPropagationEnv, "true")
 
-	time.Sleep(100 * time.Millisecond)
-	span.End()
+	var b syncbuffer.SyncBuffer
+	op, err := otel.New(otel.Config{
+		Writer: &b,
+	})
+	assert.NilError(t, err)
+	ctx := o11y.WithProvider(context.Background(), op)
 
-	var metrics []fakestatsd.Metric
-	poll.WaitOn(t, func(t poll.LogT) poll.Result {
-		m := s.Metrics()
-		if len(m) > 4 {
-			metrics = m
-			return poll.Success()
-		}
-		return poll.Continue("not enough metrics yet")
+	ctx, root := o11y.StartSpan(ctx, "root")
+	_, child := o11y.StartSpan(ctx, "child")
+	child.End()
+	assert.Check(t, !strings.Contains(b.String(), "dropped"))
+
+	// a deliberate new root is not a dropped context
+	_, newRoot := o11y.StartSpan(ctx, "new-root", o11y.WithNewRoot())
+	newRoot.End()
+	assert.Check(t, !strings.Contains(b.String(), "dropped"))
+
+	// start a span with a context that has lost the parent span
+	dropped := o11y.WithProvider(context.Background(), op)
+	_, orphan := o11y.StartSpan(dropped, "orphan")
+	orphan.End()
+	root.End()
+	assert.Check(t, cmp.Contains(b.String(), `o11y: span "orphan" started with no parent span`))
+	assert.Check(t, cmp.Contains(b.String(), "TestDebugPropagation"))
+
+	// no spans are in flight now, so this should not warn again
+	b.Reset()
+	_, later := o11y.StartSpan(dropped, "later")
+	later.End()
+	op.Close(ctx)
+	assert.Check(t, !strings.Contains(b.String(), "dropped"))
+}
+
+func TestDebugFieldCollisions(t *testing.T) {
+	t.Setenv(otel.DebugFieldCollisionsEnv, "true")
+
+	var b syncbuffer.SyncBuffer
+	op, err := otel.New(otel.Config{
+		Writer: &b,
 	})
+	assert.NilError(t, err)
+	ctx := o11y.WithProvider(context.Background(), op)
+	defer op.Close(ctx)
 
-	expected := []struct {
-		name   string
-		assert func(v string)
-	}{
-		{
-			name: "timing",
-			assert: func(v string) {
-				assertDuration(t, v, 100*time.Millisecond)
-			},
-		},
-		{
-			name: "count",
-			assert: func(v string) {
-				assertInt(t, v, 22)
-			},
-		},
-		{
-			name: "duration",
-			assert: func(v string) {
-				assertDuration(t, v, 10000*time.Millisecond)
-			},
-		},
-		{
-			name: "counter",
-			assert: func(v string) {
-				assertInt(t, v, 1)
-			},
-		},
-		{
-			name: "gauge",
-			assert: func(v string) {
-				assertInt(t, v, 34)
-			},
-		},
-	}
-	for _, e := range expected {
-		found := false
-		for _, m := range metrics {
-			if m.Name != ns+"."+e.name {
-				continue
-			}
+	ctx, span := o11y.StartSpan(ctx, "request")
+	defer span.End()
 
-			found = true
-			valParts := strings.Split(m.Value, "|")
-			assert.Check(t, len(valParts) >= 2)
-			e.assert(valParts[0])
-		}
-		assert.Check(t, found, "no metric named %s", e.name)
-	}
+	o11y.AddField(ctx, "user", "alice")
+	o11y.AddField(ctx, "user", "alice")
+	assert.Check(t, cmp.Equal(b.String(), ""))
+
+	o11y.AddField(ctx, "user", 42)
+	assert.Check(t, cmp.Equal(b.String(),
+		`o11y: field "app.user" on span "request" overwritten: alice replaced by 42`+"\n"))
 }
 
-func assertDuration(t *testing.T, v string, expected time.Duration) {
-	d, err := time.ParseDuration(v + "ms")
+func TestExportErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	t.Cleanup(srv.Close)
+
+	var mu sync.Mutex
+	var reported []error
+	op, err := otel.New(otel.Config{
+		HTTPTracesURL: srv.URL,
+		DisableText:   true,
+		OnExportError: func(err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			reported = append(reported, err)
+		},
+	})
 	assert.NilError(t, err)
-	delta := d - expected
-	assert.Check(t, delta >= 0)
+	prov := op.(*otel.Provider)
+	assert.Check(t, prov.LastExportError())
+
+	ctx := o11y.WithProvider(context.Background(), op)
+	_, span := o11y.StartSpan(ctx, "a span")
+	span.End()
+	op.Close(ctx) // force a flush
+
+	assert.Check(t, cmp.ErrorContains(prov.LastExportError(), "401"))
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Assert(t, cmp.Len(reported, 1))
+	assert.Check(t, cmp.ErrorContains(reported[0], "401"))
 }
 
-func assertInt(t *testing.T, v string, expected int) {
+type money struct {
+	pence    int
+	currency string
+}
+
+func TestRegisterSerializer(t *testing.T) {
+	var b syncbuffer.SyncBuffer
+	op, err := otel.New(otel.Config{Writer: &b})
+	assert.NilError(t, err)
+	op.(*otel.Provider).RegisterSerializer(reflect.TypeOf(money{}), func(v any) any {
+		m := v.(money)
+		return fmt.Sprintf("%d.%02d %s", m.pence/100, m.pence%100, m.currency)
+	})
+	ctx := o11y.WithProvider(context.Background(), op)
+
+	_, span := o11y.StartSpan(ctx, "span")
+	span.AddField("price", money{pence: 1250, currency: "GBP"})
+	span.AddField("price_ptr", &money{pence: 99, currency: "USD"})
+	span.AddField("nil_price", (*money)(nil))
+	span.AppendField("prices", money{pence: 100, currency: "EUR"})
+	span.End()
+	op.Close(ctx)
+
+	out := b.String()
+	assert.Check(t, cmp.Contains(out, "app.price=12.50 GBP "))
+	assert.Check(t, cmp.Contains(out, "app.price_ptr=0.99 USD "))
+	assert.Check(t, cmp.Contains(out, "app.nil_price= "))
+	assert.Check(t, cmp.Contains(out, `app.prices=["1.00 EUR"]`))
+}
+
+func TestDurationUnit(t *testing.T) {
+	tests := []struct {
+		unit   otel.DurationUnit
+		suffix string
+		expect string
+	}{
+		{unit: otel.DurationMilliseconds, suffix: "_ms", expect: "app.wait=1500\n"},
+		{unit: otel.DurationSeconds, suffix: "_s", expect: "app.wait=1.5\n"},
+		{unit: otel.DurationNanoseconds, suffix: "_ns", expect: "app.wait=1500000000\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.suffix, func(t *testing.T) {
+			var b syncbuffer.SyncBuffer
+			op, err := otel.New(otel.Config{Writer: &b, DurationUnit: tt.unit})
+			assert.NilError(t, err)
+			ctx := o11y.WithProvider(context.Background(), op)
+
+			_, span := o11y.StartSpan(ctx, "span")
+			span.AddField("wait", 1500*time.Millisecond)
+			span.End()
+			op.Close(ctx)
+
+			assert.Check(t, cmp.Equal(tt.unit.Suffix(), tt.suffix))
+			assert.Check(t, cmp.Contains(b.String(), tt.expect))
+		})
+	}
+
+	t.Run("span duration field", func(t *testing.T) {
+		for _, tt := range tests {
+			t.Run(tt.suffix, func(t *testing.T) {
+				var b syncbuffer.SyncBuffer
+				metrics := &fakemetrics.Provider{}
+				op, err := otel.New(otel.Config{Writer: &b, DurationUnit: tt.unit, Metrics: metrics})
+				assert.NilError(t, err)
+				ctx := o11y.WithProvider(context.Background(), op)
+
+				ctx, parent := o11y.StartSpan(ctx, "parent")
+				_, child := o11y.StartSpan(ctx, "child")
+				child.RecordMetric(o11y.Timing("child_time"))
+				// flattening adds the child fields, including the duration field, to the parent
+				child.Flatten("child")
+				time.Sleep(2 * time.Millisecond)
+				child.End()
+				parent.End()
+				op.Close(ctx)
+
+				assert.Check(t, cmp.Regexp(`parent .*child\.duration`+tt.suffix+`=\d`, b.String()))
+
+				var timed bool
+				for _, c := range metrics.Calls() {
+					if c.Name == "child_time" {
+						timed = true
+						assert.Check(t, c.Value >= 2, "timing metrics are in milliseconds whatever the unit, got %v", c.Value)
+					}
+				}
+				assert.Check(t, timed, "expected a timing metric")
+			})
+		}
+	})
+
+	t.Run("registered serializer", func(t *testing.T) {
+		var b syncbuffer.SyncBuffer
+		op, err := otel.New(otel.Config{Writer: &b})
+		assert.NilError(t, err)
+		op.(*otel.Provider).RegisterSerializer(reflect.TypeOf(time.Duration(0)), func(v any) any {
+			return v.(time.Duration).String()
+		})
+		ctx := o11y.WithProvider(context.Background(), op)
+
+		_, span := o11y.StartSpan(ctx, "span")
+		span.AddField("wait", 1500*time.Millisecond)
+		span.End()
+		op.Close(ctx)
+
+		assert.Check(t, cmp.Contains(b.String(), "app.wait=1.5s\n"))
+	})
+}
+
+func TestMaxSpanDepth(t *testing.T) {
+	var b syncbuffer.SyncBuffer
+	op, err := otel.New(otel.Config{
+		Writer:       &b,
+		MaxSpanDepth: 2,
+	})
+	assert.NilError(t, err)
+	ctx := o11y.WithProvider(context.Background(), op)
+
+	var recurse func(ctx context.Context, n int)
+	recurse = func(ctx context.Context, n int) {
+		if n == 0 {
+			return
+		}
+		ctx, span := o11y.StartSpan(ctx, fmt.Sprintf("depth-%d", n))
+		defer span.End()
+		recurse(ctx, n-1)
+	}
+	recurse(ctx, 5)
+	op.Close(ctx)
+
+	out := b.String()
+	assert.Check(t, cmp.Contains(out, " depth-5\n"))
+	assert.Check(t, cmp.Regexp(` depth-4 depth.truncated=3\n`, out))
+	assert.Check(t, cmp.Equal(strings.Count(out, "\n"), 2), out)
+}
+
+func TestSpanContext(t *testing.T) {
+	op, err := otel.New(otel.Config{Writer: &syncbuffer.SyncBuffer{}})
+	assert.NilError(t, err)
+	ctx := o11y.WithProvider(context.Background(), op)
+	defer op.Close(ctx)
+
+	ctx, root := o11y.StartSpan(ctx, "root")
+	defer root.End()
+
+	sc := o11y.SpanContextFromContext(ctx)
+	assert.Check(t, sc.IsValid())
+	assert.Check(t, sc.Sampled)
+	assert.Check(t, cmp.DeepEqual(sc, root.Context()))
+
+	b, err := sc.Marshal()
+	assert.NilError(t, err)
+	var remote o11y.SpanContext
+	assert.NilError(t, remote.Unmarshal(b))
+
+	// e.g. in another process, with a fresh context
+	rctx := o11y.WithProvider(context.Background(), op)
+	_, child := o11y.StartSpan(o11y.ContextFrom(rctx, remote), "child")
+	defer child.End()
+
+	assert.Check(t, cmp.Equal(child.Context().TraceID, sc.TraceID))
+	assert.Check(t, child.Context().SpanID != sc.SpanID)
+}
+
+func TestSampledFields(t *testing.T) {
+	var b syncbuffer.SyncBuffer
+	op, err := otel.New(otel.Config{
+		Writer:        &b,
+		SampledFields: map[string]uint{"app.user_id": 10},
+	})
+	assert.NilError(t, err)
+	ctx := o11y.WithProvider(context.Background(), op)
+
+	for n := 0; n < 200; n++ {
+		_, span := o11y.StartSpan(ctx, "span")
+		span.AddField("user_id", n)
+		span.AddField("org_id", "org")
+		span.End()
+	}
+	op.Close(ctx)
+
+	out := b.String()
+	assert.Check(t, cmp.Equal(strings.Count(out, "app.org_id=org"), 200))
+	users := strings.Count(out, "app.user_id=")
+	assert.Check(t, users > 2 && users < 60, "unexpected number of sampled fields: %d", users)
+}
+
+func TestBaggageFields(t *testing.T) {
+	var b syncbuffer.SyncBuffer
+	op, err := otel.New(otel.Config{
+		Writer:        &b,
+		BaggageFields: []string{"tenant-id"},
+	})
+	assert.NilError(t, err)
+	ctx := o11y.WithProvider(context.Background(), op)
+
+	// as if the baggage was extracted from an incoming request
+	ctx = o11y.WithBaggage(ctx, o11y.Baggage{"tenant-id": "t1", "other": "o"})
+	ctx, root := o11y.StartSpan(ctx, "root")
+	_, child := o11y.StartSpan(ctx, "child")
+	child.End()
+	root.End()
+	op.Close(ctx)
+
+	assert.Check(t, cmp.Contains(b.String(), "child app.tenant_id=t1\n"))
+	assert.Check(t, cmp.Contains(b.String(), "root app.tenant_id=t1\n"))
+}
+
+func TestInheritedFields(t *testing.T) {
+	var b syncbuffer.SyncBuffer
+	op, err := otel.New(otel.Config{
+		Writer:          &b,
+		InheritedFields: []string{"org_id"},
+	})
+	assert.NilError(t, err)
+	ctx := o11y.WithProvider(context.Background(), op)
+
+	ctx, root := o11y.StartSpan(ctx, "root")
+	o11y.AddFieldToTrace(ctx, "org_id", "org1")
+	o11y.AddFieldToTrace(ctx, "noisy", "lots")
+	_, child := o11y.StartSpan(ctx, "child")
+	child.End()
+	root.End()
+	op.Close(ctx)
+
+	assert.Check(t, cmp.Contains(b.String(), "child app.org_id=org1\n"))
+	assert.Check(t, cmp.Contains(b.String(), "root app.noisy=lots app.org_id=org1\n"))
+}
+
+func TestRecordCacheResult(t *testing.T) {
+	var b syncbuffer.SyncBuffer
+	op, err := otel.New(otel.Config{Writer: &b})
+	assert.NilError(t, err)
+	ctx := o11y.WithProvider(context.Background(), op)
+
+	ctx, root := o11y.StartSpan(ctx, "root")
+	for _, hit := range []bool{true, true, false} {
+		ctx, span := o11y.StartSpan(ctx, "lookup")
+		o11y.RecordCacheResult(ctx, hit, "")
+		span.End()
+	}
+	root.End()
+	op.Close(ctx)
+
+	assert.Check(t, cmp.Contains(b.String(), "lookup cache.hit=false\n"))
+	assert.Check(t, cmp.Contains(b.String(), "root cache.hits=2 cache.misses=1\n"))
+}
+
+func TestTimingBreakdown(t *testing.T) {
+	var b syncbuffer.SyncBuffer
+	op, err := otel.New(otel.Config{
+		Writer:          &b,
+		TimingBreakdown: true,
+	})
+	assert.NilError(t, err)
+	ctx := o11y.WithProvider(context.Background(), op)
+
+	ctx, root := o11y.StartSpan(ctx, "root")
+	for _, name := range []string{"db", "http", "db"} {
+		_, span := o11y.StartSpan(ctx, name)
+		time.Sleep(5 * time.Millisecond)
+		span.End()
+	}
+	root.End()
+	op.Close(ctx)
+
+	// the sleeps are lower bounds, so the two db spans add up to at least 10ms, and http at least 5ms
+	assert.Check(t, cmp.Regexp(`root timing.breakdown=db: [1-9]\d+ms, http: ([5-9]|[1-9]\d+)ms\n`, b.String()))
+}
+
+func newOtelCollector(recorder *httprecorder.RequestRecorder) http.Handler {
+	ctx := testcontext.Background()
+	r := ginrouter.Default(ctx, "fake-otel-collector")
+	r.Use(ginrecorder.Middleware(ctx, recorder))
+
+	r.POST("/v1/traces", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	return r
+}
+
+func TestMetrics(t *testing.T) {
+	ctx := context.Background()
+	s := fakestatsd.New(t)
+	ns := "test"
+	ctx, closeProvider, err := o11yconfig.Otel(context.Background(), o11yconfig.OtelConfig{
+		Service:        "app-main",
+		Version:        "dev-test",
+		Statsd:         s.Addr(),
+		StatsNamespace: ns,
+	})
+	assert.NilError(t, err)
+	defer closeProvider(ctx)
+
+	_, span := o11y.StartSpan(ctx, "test")
+	span.RecordMetric(o11y.Timing("timing"))
+
+	span.AddField("count", 22)
+	span.RecordMetric(o11y.Count("count", "count", nil))
+
+	span.AddField("custom_duration", 10000)
+	span.RecordMetric(o11y.Duration("duration", "custom_duration"))
+
+	span.RecordMetric(o11y.Incr("counter"))
+
+	span.AddRawField("gauge", 34)
+	span.RecordMetric(o11y.Gauge("gauge", "gauge"))
+
+	time.Sleep(100 * time.Millisecond)
+	span.End()
+
+	var metrics []fakestatsd.Metric
+	poll.WaitOn(t, func(t poll.LogT) poll.Result {
+		m := s.Metrics()
+		if len(m) > 4 {
+			metrics = m
+			return poll.Success()
+		}
+		return poll.Continue("not enough metrics yet")
+	})
+
+	expected := []struct {
+		name   string
+		assert func(v string)
+	}{
+		{
+			name: "timing",
+			assert: func(v string) {
+				assertDuration(t, v, 100*time.Millisecond)
+			},
+		},
+		{
+			name: "count",
+			assert: func(v string) {
+				assertInt(t, v, 22)
+			},
+		},
+		{
+			name: "duration",
+			assert: func(v string) {
+				assertDuration(t, v, 10000*time.Millisecond)
+			},
+		},
+		{
+			name: "counter",
+			assert: func(v string) {
+				assertInt(t, v, 1)
+			},
+		},
+		{
+			name: "gauge",
+			assert: func(v string) {
+				assertInt(t, v, 34)
+			},
+		},
+	}
+	for _, e := range expected {
+		found := false
+		for _, m := range metrics {
+			if m.Name != ns+"."+e.name {
+				continue
+			}
+
+			found = true
+			valParts := strings.Split(m.Value, "|")
+			assert.Check(t, len(valParts) >= 2)
+			e.assert(valParts[0])
+		}
+		assert.Check(t, found, "no metric named %s", e.name)
+	}
+}
+
+func assertDuration(t *testing.T, v string, expected time.Duration) {
+	d, err := time.ParseDuration(v + "ms")
+	assert.NilError(t, err)
+	delta := d - expected
+	assert.Check(t, delta >= 0)
+}
+
+func assertInt(t *testing.T, v string, expected int) {
 	i, err := strconv.Atoi(v)
 	assert.NilError(t, err)
 	assert.Check(t, cmp.Equal(i, expected))
 }
+
+func TestLog_CorrelationIDs(t *testing.T) {
+	var b syncbuffer.SyncBuffer
+	op, err := otel.New(otel.Config{
+		Writer: &b,
+	})
+	assert.NilError(t, err)
+	ctx := o11y.WithProvider(context.Background(), op)
+
+	o11y.Log(ctx, "no span")
+	ctx, root := o11y.StartSpan(ctx, "root")
+	sc := root.Context()
+	o11y.Log(ctx, "something happened", o11y.Field("k", "v"))
+	root.End()
+	op.Close(ctx)
+
+	assert.Check(t, cmp.Contains(b.String(),
+		"something happened app.k=v span_id="+sc.SpanID+" trace_id="+sc.TraceID+"\n"),
+		"the ids should be those of the span the event was logged in")
+
+	re := regexp.MustCompile(`no span span_id=[0-9a-f]{16} trace_id=[0-9a-f]{32}\n`)
+	assert.Check(t, re.MatchString(b.String()), "outside any span the ids are the event's own: %s", b.String())
+}
+
+func TestWithOnEnd(t *testing.T) {
+	var b syncbuffer.SyncBuffer
+	op, err := otel.New(otel.Config{Writer: &b})
+	assert.NilError(t, err)
+	ctx := o11y.WithProvider(context.Background(), op)
+
+	_, span := o11y.StartSpan(ctx, "span", o11y.WithOnEnd(func(s o11y.Span) {
+		s.AddRawField("ended", true)
+	}))
+	span.End()
+
+	ctx = o11y.WithBudget(ctx, time.Second)
+	_, span = o11y.StartSpan(ctx, "budgeted")
+	span.End()
+	op.Close(ctx)
+
+	assert.Check(t, cmp.Contains(b.String(), "span ended=true\n"))
+	assert.Check(t, cmp.Regexp(`budgeted budget.consumed_ms=\S+ budget.remaining_at_end_ms=\S+ budget.remaining_ms=1000\n`,
+		b.String()))
+}
+
+func TestStartSpan_CancelledContext(t *testing.T) {
+	var b syncbuffer.SyncBuffer
+	op, err := otel.New(otel.Config{
+		Writer: &b,
+	})
+	assert.NilError(t, err)
+	ctx := o11y.WithProvider(context.Background(), op)
+
+	ctx, root := o11y.StartSpan(ctx, "root")
+	cctx, cancel := context.WithCancel(ctx)
+	cancel()
+	_, abandoned := o11y.StartSpan(cctx, "abandoned")
+	abandoned.End()
+	root.End()
+	op.Close(ctx)
+
+	assert.Check(t, cmp.Contains(b.String(), "abandoned started_cancelled=true\n"))
+	assert.Check(t, !strings.Contains(b.String(), "root started_cancelled"))
+}
+
+func TestLogSampleRate(t *testing.T) {
+	var b syncbuffer.SyncBuffer
+	op, err := otel.New(otel.Config{
+		Writer:        &b,
+		LogSampleRate: 4,
+	})
+	assert.NilError(t, err)
+	ctx := o11y.WithProvider(context.Background(), op)
+
+	ctx, root := o11y.StartSpan(ctx, "root")
+	for i := 0; i < 8; i++ {
+		o11y.Log(ctx, "chatty")
+	}
+	o11y.LogError(ctx, "failed", errors.New("boom"))
+	root.End()
+	op.Close(ctx)
+
+	assert.Check(t, cmp.Equal(strings.Count(b.String(), " chatty"), 2))
+	assert.Check(t, cmp.Contains(b.String(), " failed "))
+	assert.Check(t, cmp.Contains(b.String(), "root logs.sampled_out=6"))
+}
+
+func TestLogDedupThreshold(t *testing.T) {
+	col, addr := startTestCollector(t)
+	op, err := otel.New(otel.Config{
+		GrpcHostAndPort:   addr,
+		LogDedupThreshold: 2,
+	})
+	assert.NilError(t, err)
+	ctx := o11y.WithProvider(context.Background(), op)
+
+	rctx, root := o11y.StartSpan(ctx, "root")
+	for i := 0; i < 10; i++ {
+		o11y.Log(rctx, "retry failed", o11y.Field("attempt", i))
+	}
+	o11y.Log(rctx, "other")
+	root.End()
+	o11y.Log(ctx, "no parent")
+	o11y.Log(ctx, "no parent")
+	o11y.Log(ctx, "no parent")
+	op.Close(ctx)
+
+	var retries []CollectSpan
+	others := 0
+	for _, s := range col.Spans() {
+		switch s.Name {
+		case "retry failed":
+			retries = append(retries, s)
+		case "other", "no parent":
+			others++
+		}
+	}
+	assert.Check(t, cmp.Equal(others, 4))
+	assert.Assert(t, cmp.Len(retries, 3))
+	collapsed := retries[2]
+	assert.Check(t, cmp.Equal(collapsed.Attrs["count"], "8"))
+	assert.Check(t, cmp.Equal(collapsed.Attrs["app.attempt"], "2"), "the fields of the first collapsed event are kept")
+	first, err := time.Parse(time.RFC3339Nano, collapsed.Attrs["first_at"])
+	assert.NilError(t, err)
+	last, err := time.Parse(time.RFC3339Nano, collapsed.Attrs["last_at"])
+	assert.NilError(t, err)
+	assert.Check(t, !last.Before(first))
+}
+
+func TestCopyFields(t *testing.T) {
+	var b syncbuffer.SyncBuffer
+	op, err := otel.New(otel.Config{Writer: &b})
+	assert.NilError(t, err)
+	ctx := o11y.WithProvider(context.Background(), op)
+
+	ctx, parent := o11y.StartSpan(ctx, "parent")
+	parent.AddField("handler", "build")
+	_, child := o11y.StartSpan(ctx, "child")
+	o11y.CopyFields(parent, child, "app.handler", "app.missing")
+
+	val, ok := o11y.GetField(child, "app.handler")
+	assert.Check(t, ok)
+	assert.Check(t, cmp.Equal(val, "build"))
+	_, ok = o11y.GetField(child, "app.missing")
+	assert.Check(t, !ok)
+
+	child.End()
+	parent.End()
+	op.Close(ctx)
+	assert.Check(t, cmp.Contains(b.String(), "child app.handler=build\n"))
+}
+
+func TestFieldsSnapshot(t *testing.T) {
+	op, err := otel.New(otel.Config{Writer: &syncbuffer.SyncBuffer{}})
+	assert.NilError(t, err)
+	ctx := o11y.WithProvider(context.Background(), op)
+	defer op.Close(ctx)
+
+	_, span := o11y.StartSpan(ctx, "span")
+	defer span.End()
+	span.AddField("handler", "build")
+	before := o11y.FieldsSnapshot(span)
+	span.AddRawField("http.method", "GET")
+
+	assert.Check(t, cmp.DeepEqual(before, map[string]any{"app.handler": "build"}))
+	assert.Check(t, cmp.DeepEqual(o11y.FieldsSnapshot(span), map[string]any{
+		"app.handler": "build",
+		"http.method": "GET",
+	}))
+}
+
+func TestNameValidator(t *testing.T) {
+	var b syncbuffer.SyncBuffer
+	op, err := otel.New(otel.Config{
+		Writer: &b,
+		NameValidator: func(name string) error {
+			if !strings.Contains(name, " /") {
+				return errors.New(`expected "METHOD /route"`)
+			}
+			return nil
+		},
+	})
+	assert.NilError(t, err)
+	ctx := o11y.WithProvider(context.Background(), op)
+
+	ctx, good := o11y.StartSpan(ctx, "GET /builds")
+	_, bad := o11y.StartSpan(ctx, "builds")
+	bad.End()
+	good.End()
+	op.Close(ctx)
+
+	assert.Check(t, cmp.Contains(b.String(),
+		`builds name.invalid=true name.invalid_reason=expected "METHOD /route"`+"\n"))
+	assert.Check(t, cmp.Contains(b.String(), "GET /builds\n"))
+}
+
+func TestSpanStartTime(t *testing.T) {
+	op, err := otel.New(otel.Config{Writer: &syncbuffer.SyncBuffer{}})
+	assert.NilError(t, err)
+	ctx := o11y.WithProvider(context.Background(), op)
+	defer op.Close(ctx)
+
+	before := time.Now()
+	_, span := o11y.StartSpan(ctx, "span")
+	after := time.Now()
+	defer span.End()
+
+	start := span.StartTime()
+	assert.Check(t, !start.Before(before) && !start.After(after), start)
+}
+
+func TestRecentTraces(t *testing.T) {
+	op, err := otel.New(otel.Config{
+		Writer:       &syncbuffer.SyncBuffer{},
+		RecentTraces: 2,
+	})
+	assert.NilError(t, err)
+	ctx := o11y.WithProvider(context.Background(), op)
+	defer op.Close(ctx)
+	p := op.(*otel.Provider)
+
+	assert.Check(t, cmp.Len(p.RecentTraces(), 0))
+
+	for _, name := range []string{"first", "second", "third"} {
+		ctx, root := o11y.StartSpan(ctx, name)
+		_, child := o11y.StartSpan(ctx, name+"-child")
+		child.End()
+		root.End()
+	}
+	// a trace that has not finished yet
+	_, unfinished := o11y.StartSpan(ctx, "unfinished")
+	defer unfinished.End()
+
+	traces := p.RecentTraces()
+	assert.Assert(t, cmp.Len(traces, 2))
+	for i, name := range []string{"third", "second"} {
+		tr := traces[i]
+		assert.Assert(t, cmp.Len(tr.Spans, 2))
+		assert.Check(t, cmp.Equal(tr.Spans[0].Name, name+"-child"))
+		assert.Check(t, cmp.Equal(tr.Spans[1].Name, name))
+		assert.Check(t, cmp.Equal(tr.Spans[1].TraceID, tr.TraceID))
+	}
+}
+
+func TestSampleByTraceID_Debug(t *testing.T) {
+	col, addr := startTestCollector(t)
+
+	prov, err := otel.New(otel.Config{
+		GrpcHostAndPort: addr,
+		SampleTraces:    true,
+		SampleKeyFunc:   func(map[string]any) string { return "all" },
+		SampleRates:     map[string]uint{"all": 2},
+		SampleByTraceID: true,
+		SamplingDebug:   true,
+	})
+	assert.NilError(t, err)
+	ctx := o11y.WithProvider(context.Background(), prov)
+	for n := 0; n < 50; n++ {
+		ctx, root := prov.StartSpan(ctx, "root")
+		root.AddField("trace", n)
+		for i := 0; i < 2; i++ {
+			_, child := prov.StartSpan(ctx, "child")
+			child.AddField("trace", n)
+			child.End()
+		}
+		root.End()
+	}
+	prov.Close(ctx)
+
+	byTrace := map[string][]CollectSpan{}
+	for _, s := range col.Spans() {
+		byTrace[s.Attrs["app.trace"]] = append(byTrace[s.Attrs["app.trace"]], s)
+	}
+	assert.Check(t, len(byTrace) > 5 && len(byTrace) < 45, "unexpected number of traces: %d", len(byTrace))
+	for n, spans := range byTrace {
+		assert.Check(t, cmp.Len(spans, 3), "trace %s was only partially kept", n)
+		determinant := spans[0].Attrs["sampling.determinant"]
+		assert.Check(t, cmp.Regexp(`^trace_id:[0-9a-f]{8}$`, determinant))
+		for _, s := range spans {
+			assert.Check(t, cmp.Equal(s.Attrs["sampling.determinant"], determinant))
+		}
+	}
+}
+
+func TestSampleDryRun(t *testing.T) {
+	col, addr := startTestCollector(t)
+
+	prov, err := otel.New(otel.Config{
+		GrpcHostAndPort: addr,
+		SampleTraces:    true,
+		SampleKeyFunc:   func(map[string]any) string { return "all" },
+		SampleRates:     map[string]uint{"all": 2},
+		SampleDryRun:    true,
+	})
+	assert.NilError(t, err)
+	ctx := o11y.WithProvider(context.Background(), prov)
+	for n := 0; n < 50; n++ {
+		_, span := prov.StartSpan(ctx, "span")
+		span.End()
+	}
+	prov.Close(ctx)
+
+	spans := col.Spans()
+	assert.Check(t, cmp.Len(spans, 50))
+	wouldDrop := 0
+	for _, s := range spans {
+		assert.Check(t, cmp.Equal(s.Attrs["SampleRate"], "1"))
+		if s.Attrs["sampling.would_drop"] == "true" {
+			wouldDrop++
+		}
+	}
+	assert.Check(t, wouldDrop > 5 && wouldDrop < 45, "unexpected number of would drop spans: %d", wouldDrop)
+}
+
+func TestSpanStatus(t *testing.T) {
+	col, addr := startTestCollector(t)
+
+	prov, err := otel.New(otel.Config{
+		GrpcHostAndPort: addr,
+		DisableText:     true,
+	})
+	assert.NilError(t, err)
+	ctx := o11y.WithProvider(context.Background(), prov)
+
+	_, span := o11y.StartSpan(ctx, "ok")
+	o11y.AddResultToSpan(span, nil)
+	span.End()
+	_, span = o11y.StartSpan(ctx, "failed")
+	o11y.AddResultToSpan(span, errors.New("boom"))
+	span.End()
+	_, span = o11y.StartSpan(ctx, "canceled")
+	o11y.AddResultToSpan(span, context.Canceled)
+	span.End()
+	prov.Close(ctx)
+
+	codes := map[string]string{}
+	for _, s := range col.Spans() {
+		codes[s.Name] = s.Code + ":" + s.Desc
+	}
+	assert.Check(t, cmp.DeepEqual(codes, map[string]string{
+		"ok":       "STATUS_CODE_OK:",
+		"failed":   "STATUS_CODE_ERROR:boom",
+		"canceled": "STATUS_CODE_UNSET:",
+	}))
+}
+
+func TestChildCounts(t *testing.T) {
+	col, addr := startTestCollector(t)
+
+	op, err := otel.New(otel.Config{
+		GrpcHostAndPort: addr,
+		DisableText:     true,
+		ChildCounts:     true,
+	})
+	assert.NilError(t, err)
+	ctx := o11y.WithProvider(context.Background(), op)
+
+	ctx, root := o11y.StartSpan(ctx, "root")
+	ctx2, handler := o11y.StartSpan(ctx, "handler")
+	for i := 0; i < 3; i++ {
+		_, query := o11y.StartSpan(ctx2, "query")
+		query.End()
+	}
+	handler.End()
+	root.End()
+	op.Close(ctx)
+
+	spans := map[string]CollectSpan{}
+	for _, s := range col.Spans() {
+		spans[s.Name] = s
+	}
+	assert.Check(t, cmp.Equal(spans["query"].Attrs["span.child_count"], ""))
+	assert.Check(t, cmp.Equal(spans["handler"].Attrs["span.child_count"], "3"))
+	assert.Check(t, cmp.Equal(spans["handler"].Attrs["trace.span_count"], ""))
+	assert.Check(t, cmp.Equal(spans["root"].Attrs["span.child_count"], "1"))
+	assert.Check(t, cmp.Equal(spans["root"].Attrs["trace.span_count"], "5"))
+}
+
+// legacyPropagator propagates the trace id in a proprietary header
+type legacyPropagator struct{}
+
+const legacyHeader = "X-Legacy-Trace"
+
+func (legacyPropagator) Inject(ctx context.Context, carrier propagation.TextMapCarrier) {
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		carrier.Set(legacyHeader, sc.TraceID().String())
+	}
+}
+
+func (legacyPropagator) Extract(ctx context.Context, carrier propagation.TextMapCarrier) context.Context {
+	if trace.SpanContextFromContext(ctx).IsValid() {
+		return ctx
+	}
+	traceID, err := trace.TraceIDFromHex(carrier.Get(legacyHeader))
+	if err != nil {
+		return ctx
+	}
+	return trace.ContextWithRemoteSpanContext(ctx, trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     trace.SpanID{1},
+		TraceFlags: trace.FlagsSampled,
+	}))
+}
+
+func (legacyPropagator) Fields() []string {
+	return []string{legacyHeader}
+}
+
+func TestPropagators(t *testing.T) {
+	op, err := otel.New(otel.Config{
+		Writer:      &syncbuffer.SyncBuffer{},
+		Propagators: []propagation.TextMapPropagator{legacyPropagator{}},
+	})
+	assert.NilError(t, err)
+	ctx := o11y.WithProvider(context.Background(), op)
+	defer op.Close(ctx)
+
+	t.Run("inject", func(t *testing.T) {
+		ctx, span := o11y.StartSpan(ctx, "span")
+		defer span.End()
+
+		headers := op.Helpers().ExtractPropagation(ctx).Headers
+		assert.Check(t, cmp.Equal(headers.Get(legacyHeader), span.Context().TraceID))
+		assert.Check(t, headers.Get("traceparent") != "", "w3c propagation should remain")
+	})
+
+	t.Run("extract", func(t *testing.T) {
+		traceID := "0102030405060708090a0b0c0d0e0f10"
+		headers := http.Header{}
+		headers.Set(legacyHeader, traceID)
+
+		_, span := op.Helpers().InjectPropagation(ctx, o11y.PropagationContext{Headers: headers})
+		defer span.End()
+		assert.Check(t, cmp.Equal(span.Context().TraceID, traceID))
+	})
+}
+
+func TestSampleRates(t *testing.T) {
+	t.Run("not sampling", func(t *testing.T) {
+		op, err := otel.New(otel.Config{Writer: &syncbuffer.SyncBuffer{}})
+		assert.NilError(t, err)
+		p := op.(*otel.Provider)
+		p.UpdateSampleRates(map[string]uint{"a": 2})
+		assert.Check(t, cmp.Nil(p.SampleRates()))
+	})
+
+	var b syncbuffer.SyncBuffer
+	op, err := otel.New(otel.Config{
+		Writer:        &b,
+		SampleTraces:  true,
+		SampleKeyFunc: func(m map[string]any) string { return m["span.name"].(string) },
+		SampleRates:   map[string]uint{"dropped": 1e9},
+	})
+	assert.NilError(t, err)
+	ctx := o11y.WithProvider(context.Background(), op)
+	p := op.(*otel.Provider)
+
+	rates := p.SampleRates()
+	assert.Check(t, cmp.DeepEqual(rates, map[string]uint{"dropped": 1e9}))
+	// mutating the copy does not change the rates in effect
+	rates["dropped"] = 1
+	assert.Check(t, cmp.DeepEqual(p.SampleRates(), map[string]uint{"dropped": 1e9}))
+
+	// the rates are applied as spans are exported
+	p.UpdateSampleRates(map[string]uint{"dropped": 1})
+	assert.Check(t, cmp.DeepEqual(p.SampleRates(), map[string]uint{"dropped": 1}))
+	_, span := o11y.StartSpan(ctx, "dropped")
+	span.End()
+	op.Close(ctx)
+	assert.Check(t, cmp.Contains(b.String(), "dropped SampleRate=1"))
+}
+
+func TestRecordBytes(t *testing.T) {
+	var b syncbuffer.SyncBuffer
+	op, err := otel.New(otel.Config{Writer: &b})
+	assert.NilError(t, err)
+	ctx := o11y.WithProvider(context.Background(), op)
+
+	ctx, span := o11y.StartSpan(ctx, "stream")
+	g := &errgroup.Group{}
+	for n := 0; n < 100; n++ {
+		g.Go(func() error {
+			o11y.RecordBytesRead(ctx, 3)
+			o11y.RecordBytesWritten(ctx, 1)
+			return nil
+		})
+	}
+	assert.Check(t, g.Wait())
+	span.End()
+	op.Close(ctx)
+
+	assert.Check(t, cmp.Contains(b.String(), "stream io.bytes_read=300 io.bytes_written=100\n"))
+}
+
+func TestStreamSpan(t *testing.T) {
+	t.Run("completed", func(t *testing.T) {
+		var b syncbuffer.SyncBuffer
+		op, err := otel.New(otel.Config{Writer: &b})
+		assert.NilError(t, err)
+		ctx := o11y.WithProvider(context.Background(), op)
+
+		ctx, done := o11y.StartStreamSpan(ctx, "stream")
+		g := &errgroup.Group{}
+		for n := 0; n < 100; n++ {
+			g.Go(func() error {
+				o11y.RecordStreamProgress(ctx, 1, 10)
+				return nil
+			})
+		}
+		assert.Check(t, g.Wait())
+		done()
+		op.Close(ctx)
+
+		assert.Check(t, cmp.Contains(b.String(), "stream stream.bytes=1000 stream.events=100\n"))
+	})
+
+	t.Run("abandoned", func(t *testing.T) {
+		var b syncbuffer.SyncBuffer
+		op, err := otel.New(otel.Config{Writer: &b, Test: true})
+		assert.NilError(t, err)
+		ctx := o11y.WithProvider(context.Background(), op)
+
+		ctx, cancel := context.WithCancel(ctx)
+		sctx, done := o11y.StartStreamSpan(ctx, "stream")
+		o11y.RecordStreamProgress(sctx, 2, 20)
+		cancel()
+		poll.WaitOn(t, func(poll.LogT) poll.Result {
+			// test mode exports synchronously, but colours the span name
+			if strings.Contains(b.String(), " stream.abandoned=true stream.bytes=20 stream.events=2\n") {
+				return poll.Success()
+			}
+			return poll.Continue("span not ended: %q", b.String())
+		})
+
+		done()
+		op.Close(context.Background())
+		assert.Check(t, cmp.Equal(strings.Count(b.String(), "\n"), 1), "the span should only end once")
+	})
+}
+
+func TestRecordThrottle(t *testing.T) {
+	var b syncbuffer.SyncBuffer
+	op, err := otel.New(otel.Config{Writer: &b})
+	assert.NilError(t, err)
+	ctx := o11y.WithProvider(context.Background(), op)
+
+	ctx, root := o11y.StartSpan(ctx, "root")
+	ctx2, call := o11y.StartSpan(ctx, "call")
+	o11y.RecordThrottle(ctx2, "too many requests", 2*time.Second)
+	call.End()
+	_, other := o11y.StartSpan(ctx, "other")
+	other.End()
+	root.End()
+	op.Close(ctx)
+
+	out := b.String()
+	assert.Check(t, cmp.Contains(out,
+		"call app.throttled=true throttle.reason=too many requests throttle.retry_after_ms=2000 throttled=true\n"))
+	assert.Check(t, cmp.Contains(out, "other app.throttled=true\n"))
+	assert.Check(t, cmp.Contains(out, "root app.throttled=true\n"))
+}
+
+func TestSetActor(t *testing.T) {
+	var b syncbuffer.SyncBuffer
+	op, err := otel.New(otel.Config{
+		Writer:         &b,
+		RedactPatterns: []*regexp.Regexp{regexp.MustCompile(`[a-z]+@example\.com`)},
+	})
+	assert.NilError(t, err)
+	ctx := o11y.WithProvider(context.Background(), op)
+
+	rctx, root := o11y.StartSpan(ctx, "root")
+	o11y.SetActor(rctx, o11y.Actor{ID: "user-1", Type: o11y.ActorUser, OrgID: "org-1"})
+	_, child := o11y.StartSpan(rctx, "child")
+	child.End()
+	root.End()
+
+	bctx, bot := o11y.StartSpan(ctx, "bot")
+	o11y.SetActor(bctx, o11y.Actor{ID: "bot@example.com", Type: o11y.ActorBot})
+	bot.End()
+	op.Close(ctx)
+
+	out := b.String()
+	assert.Check(t, cmp.Contains(out, "child app.actor.id=user-1 app.actor.org_id=org-1 app.actor.type=user\n"))
+	assert.Check(t, cmp.Contains(out, "root app.actor.id=user-1 app.actor.org_id=org-1 app.actor.type=user\n"))
+	assert.Check(t, cmp.Contains(out, "bot app.actor.id=[REDACTED] app.actor.type=bot\n"))
+}
+
+func TestStartJobSpan(t *testing.T) {
+	var b syncbuffer.SyncBuffer
+	op, err := otel.New(otel.Config{Writer: &b})
+	assert.NilError(t, err)
+	ctx := o11y.WithProvider(context.Background(), op)
+
+	ctx, parent := o11y.StartSpan(ctx, "scheduler")
+	scheduledAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	_, job := o11y.StartJobSpan(ctx, "cleanup", scheduledAt)
+	job.End()
+	parent.End()
+	op.Close(ctx)
+
+	assert.Check(t, job.Context().TraceID != parent.Context().TraceID, "the job span should start a new trace")
+	re := regexp.MustCompile(`job: cleanup job.delay_ms=(\d+) job.name=cleanup job.scheduled_at=2024-01-02T03:04:05Z\n`)
+	m := re.FindStringSubmatch(b.String())
+	assert.Assert(t, cmp.Len(m, 2), b.String())
+	delay, err := strconv.ParseInt(m[1], 10, 64)
+	assert.NilError(t, err)
+	assert.Check(t, delay >= time.Since(scheduledAt).Milliseconds()-60000, delay)
+}
+
+func TestWithGlobalFields(t *testing.T) {
+	col, addr := startTestCollector(t)
+
+	prov, err := otel.New(otel.Config{GrpcHostAndPort: addr})
+	assert.NilError(t, err)
+	plugin := prov.(*otel.Provider).WithGlobalFields(map[string]any{"service": "plugin", "plugin_id": 7})
+
+	ctx := o11y.WithProvider(context.Background(), plugin)
+	ctx, span := o11y.StartSpan(ctx, "plugin span")
+	_, child := o11y.StartSpan(ctx, "plugin child")
+	child.End()
+	span.End()
+	plugin.Close(ctx)
+
+	_, other := prov.StartSpan(context.Background(), "other span")
+	other.End()
+	prov.Close(context.Background())
+
+	spans := map[string]CollectSpan{}
+	for _, s := range col.Spans() {
+		spans[s.Name] = s
+	}
+	assert.Assert(t, cmp.Len(spans, 3), "closing the derived provider should not close the original")
+	for _, name := range []string{"plugin span", "plugin child"} {
+		assert.Check(t, cmp.Equal(spans[name].Attrs["service"], "plugin"), name)
+		assert.Check(t, cmp.Equal(spans[name].Attrs["plugin_id"], "7"), name)
+	}
+	_, ok := spans["other span"].Attrs["plugin_id"]
+	assert.Check(t, !ok)
+}
+
+func TestMaxTraceFields(t *testing.T) {
+	var b syncbuffer.SyncBuffer
+	op, err := otel.New(otel.Config{
+		Writer:         &b,
+		MaxTraceFields: 2,
+	})
+	assert.NilError(t, err)
+	ctx := o11y.WithProvider(context.Background(), op)
+
+	ctx, root := o11y.StartSpan(ctx, "root")
+	for i := 0; i < 5; i++ {
+		o11y.AddFieldToTrace(ctx, fmt.Sprintf("f%d", i), i)
+	}
+	// updating an existing field is not capped
+	o11y.AddFieldToTrace(ctx, "f0", "updated")
+	_, child := o11y.StartSpan(ctx, "child")
+	child.End()
+	root.End()
+	op.Close(ctx)
+
+	assert.Check(t, cmp.Contains(b.String(), "child app.f0=updated app.f1=1\n"))
+	assert.Check(t, cmp.Contains(b.String(), "root app.f0=updated app.f1=1 trace_fields.dropped=3\n"))
+}
+
+func TestWorkflowStep(t *testing.T) {
+	col, addr := startTestCollector(t)
+	prov, err := otel.New(otel.Config{GrpcHostAndPort: addr, DisableText: true})
+	assert.NilError(t, err)
+	ctx := o11y.WithProvider(context.Background(), prov)
+
+	// each step would typically run in its own process, so they are not nested
+	_, reserve := o11y.StartWorkflowStep(ctx, "checkout", "reserve")
+	reserved := reserve.Context()
+	reserve.End()
+
+	stepCtx, charge := o11y.StartWorkflowStep(ctx, "checkout", "charge", reserved, o11y.SpanContext{})
+	o11y.RecordWorkflowStep(stepCtx, "checkout", "charge", "completed")
+	charge.End()
+	prov.Close(ctx)
+
+	spans := col.Spans()
+	assert.Assert(t, cmp.Len(spans, 2))
+	assert.Check(t, cmp.Equal(spans[1].Name, "workflow: checkout.charge"))
+	assert.Check(t, cmp.Equal(spans[1].Attrs["workflow.name"], "checkout"))
+	assert.Check(t, cmp.Equal(spans[1].Attrs["workflow.step"], "charge"))
+	assert.Check(t, cmp.Equal(spans[1].Attrs["workflow.step_status"], "completed"))
+	// the invalid span context is ignored
+	assert.Check(t, cmp.DeepEqual(spans[1].Links, []string{reserved.TraceID + ":" + reserved.SpanID}))
+	assert.Check(t, cmp.Len(spans[0].Links, 0))
+}
+
+func TestParentIDField(t *testing.T) {
+	col, addr := startTestCollector(t)
+	prov, err := otel.New(otel.Config{GrpcHostAndPort: addr, DisableText: true, ParentIDField: true})
+	assert.NilError(t, err)
+	ctx := o11y.WithProvider(context.Background(), prov)
+
+	rootCtx, root := o11y.StartSpan(ctx, "root")
+	_, child := o11y.StartSpan(rootCtx, "child")
+	child.End()
+	_, newRoot := o11y.StartSpan(rootCtx, "new root", o11y.WithNewRoot())
+	newRoot.End()
+	root.End()
+
+	remote := o11y.SpanContext{TraceID: "0af7651916cd43dd8448eb211c80319c", SpanID: "b7ad6b7169203331", Sampled: true}
+	_, fromRemote := o11y.StartSpan(o11y.ContextFrom(ctx, remote), "from remote")
+	fromRemote.End()
+	prov.Close(ctx)
+
+	parents := map[string]string{}
+	for _, s := range col.Spans() {
+		if id, ok := s.Attrs["trace.parent_id"]; ok {
+			parents[s.Name] = id
+		}
+	}
+	assert.Check(t, cmp.DeepEqual(parents, map[string]string{
+		"child":       root.Context().SpanID,
+		"from remote": remote.SpanID,
+	}))
+}
+
+func TestCompression(t *testing.T) {
+	for _, compression := range []otel.Compression{otel.CompressionNone, otel.CompressionGzip} {
+		t.Run(string(compression), func(t *testing.T) {
+			col, addr := startTestCollector(t)
+			metrics := &fakemetrics.Provider{}
+			prov, err := otel.New(otel.Config{
+				GrpcHostAndPort: addr,
+				DisableText:     true,
+				Compression:     compression,
+				Metrics:         metrics,
+			})
+			assert.NilError(t, err)
+			ctx := o11y.WithProvider(context.Background(), prov)
+
+			for i := 0; i < 100; i++ {
+				_, span := o11y.StartSpan(ctx, "compressible")
+				span.AddField("payload", strings.Repeat("a", 100))
+				span.End()
+			}
+			prov.Close(ctx)
+			assert.Check(t, cmp.Len(col.Spans(), 100))
+
+			var compressed, uncompressed int64
+			for _, c := range metrics.Calls() {
+				assert.Check(t, cmp.DeepEqual(c.Tags, []string{"exporter:grpc", "compression:" + string(compression)}))
+				switch c.Name {
+				case "otel.export.bytes":
+					compressed += c.ValueInt
+				case "otel.export.uncompressed_bytes":
+					uncompressed += c.ValueInt
+				}
+			}
+			assert.Check(t, uncompressed > 10_000, uncompressed)
+			if compression == otel.CompressionGzip {
+				assert.Check(t, compressed < uncompressed/2, "%d !< %d/2", compressed, uncompressed)
+			} else {
+				assert.Check(t, cmp.Equal(compressed, uncompressed))
+			}
+		})
+	}
+}
+
+func TestBuildInfo(t *testing.T) {
+	for _, disabled := range []bool{false, true} {
+		t.Run(fmt.Sprintf("disabled=%v", disabled), func(t *testing.T) {
+			col, addr := startTestCollector(t)
+			prov, err := otel.New(otel.Config{GrpcHostAndPort: addr, DisableText: true, DisableBuildInfo: disabled})
+			assert.NilError(t, err)
+			ctx := o11y.WithProvider(context.Background(), prov)
+
+			_, span := o11y.StartSpan(ctx, "span")
+			span.End()
+			prov.Close(ctx)
+
+			want := runtime.Version()
+			if disabled {
+				want = ""
+			}
+			// N.B. test binaries have no vcs build info
+			assert.Check(t, cmp.Equal(col.ResourceAttribute("service.go_version"), want))
+		})
+	}
+}
+
+func TestCutShortFields(t *testing.T) {
+	var b syncbuffer.SyncBuffer
+	prov, err := otel.New(otel.Config{Writer: &b, SyncExport: true, CutShortFields: true})
+	assert.NilError(t, err)
+	ctx := o11y.WithProvider(context.Background(), prov)
+	defer prov.Close(ctx)
+
+	reqCtx, cancel := context.WithCancelCause(ctx)
+	reqCtx, root := o11y.StartSpan(reqCtx, "request")
+	_, open := o11y.StartSpan(reqCtx, "open")
+	_, failed := o11y.StartSpan(reqCtx, "failed")
+	_, completed := o11y.StartSpan(reqCtx, "completed")
+	completed.End()
+
+	cancel(errors.New("client disconnected"))
+	o11y.AddResultToSpan(failed, reqCtx.Err())
+	failed.End()
+	open.End()
+	root.End()
+
+	lines := map[string]string{}
+	for _, l := range strings.Split(strings.TrimSpace(b.String()), "\n") {
+		for _, name := range []string{"request", "open", "failed", "completed"} {
+			if strings.Contains(l, " "+name) {
+				lines[name] = l
+			}
+		}
+	}
+	assert.Check(t, cmp.Contains(lines["open"], "cut_short=true cut_short.cause=client disconnected"))
+	assert.Check(t, cmp.Contains(lines["request"], "cut_short=true"))
+	assert.Check(t, !strings.Contains(lines["failed"], "cut_short"), lines["failed"])
+	assert.Check(t, !strings.Contains(lines["completed"], "cut_short"), lines["completed"])
+}
+
+// fixedRateSampler keeps every span, with a SampleRate of rate
+type fixedRateSampler struct {
+	rate int
+}
+
+func (s fixedRateSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	return sdktrace.SamplingResult{
+		Decision:   sdktrace.RecordAndSample,
+		Attributes: []attribute.KeyValue{attribute.Int("SampleRate", s.rate)},
+		Tracestate: trace.SpanContextFromContext(p.ParentContext).TraceState(),
+	}
+}
+
+func (s fixedRateSampler) Description() string { return "fixed" }
+
+func TestPropagateSampleRate(t *testing.T) {
+	col, addr := startTestCollector(t)
+
+	upstream, err := otel.New(otel.Config{
+		DisableText:         true,
+		Sampler:             sdktrace.ParentBased(fixedRateSampler{rate: 4}),
+		PropagateSampleRate: true,
+	})
+	assert.NilError(t, err)
+	downstream, err := otel.New(otel.Config{
+		GrpcHostAndPort:     addr,
+		DisableText:         true,
+		PropagateSampleRate: true,
+	})
+	assert.NilError(t, err)
+
+	ctx := o11y.WithProvider(context.Background(), upstream)
+	ctx, root := o11y.StartSpan(ctx, "upstream")
+	headers := upstream.Helpers().ExtractPropagation(ctx)
+	assert.Check(t, cmp.Contains(headers.Headers.Get("tracestate"), "o11y=sr:4"))
+	root.End()
+	upstream.Close(ctx)
+
+	dctx := o11y.WithProvider(context.Background(), downstream)
+	dctx, server := downstream.Helpers().InjectPropagation(dctx, headers)
+	server.AddRawField("name", "downstream")
+	_, child := o11y.StartSpan(dctx, "downstream child")
+	child.End()
+	server.End()
+	downstream.Close(dctx)
+
+	spans := col.Spans()
+	assert.Assert(t, cmp.Len(spans, 2))
+	for _, s := range spans {
+		assert.Check(t, cmp.Equal(s.Attrs["SampleRate"], "4"), s.Name)
+	}
+}
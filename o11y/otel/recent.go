@@ -0,0 +1,107 @@
+package otel
+
+import (
+	"context"
+	"sync"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// maxPendingTraces bounds the traces that are tracked while waiting for their root span to finish,
+// so that traces whose root never ends cannot grow the memory used without limit. Beyond it, the
+// oldest pending trace is evicted to make room for a new one.
+const maxPendingTraces = 1000
+
+// Trace is a finished trace, as retained for RecentTraces. Only the spans created by this process are
+// included, ending with the (local) root span.
+type Trace struct {
+	TraceID string
+	Spans   []SpanSnapshot
+}
+
+// recentTraces is an exporter that retains the most recent finished traces in a ring buffer.
+// A trace is finished when its local root span ends, i.e. a span with no parent, or a remote parent.
+type recentTraces struct {
+	mu      sync.Mutex
+	pending map[string]*recentPending
+	seq     uint64
+	ring    []Trace
+	next    int
+	full    bool
+}
+
+// recentPending is a trace waiting for its root span to finish. The seq orders the pending traces by
+// when they were first seen, so the oldest can be evicted.
+type recentPending struct {
+	seq   uint64
+	spans []SpanSnapshot
+}
+
+func newRecentTraces(n int) *recentTraces {
+	return &recentTraces{
+		pending: map[string]*recentPending{},
+		ring:    make([]Trace, n),
+	}
+}
+
+func (r *recentTraces) ExportSpans(_ context.Context, spans []sdktrace.ReadOnlySpan) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, s := range spans {
+		ss := snapshot(s)
+		isRoot := !s.Parent().IsValid() || s.Parent().IsRemote()
+		p, ok := r.pending[ss.TraceID]
+		if !ok {
+			if !isRoot && len(r.pending) >= maxPendingTraces {
+				delete(r.pending, r.oldestLocked())
+			}
+			r.seq++
+			p = &recentPending{seq: r.seq}
+		}
+		p.spans = append(p.spans, ss)
+		if !isRoot {
+			r.pending[ss.TraceID] = p
+			continue
+		}
+
+		delete(r.pending, ss.TraceID)
+		r.ring[r.next] = Trace{TraceID: ss.TraceID, Spans: p.spans}
+		r.next = (r.next + 1) % len(r.ring)
+		if r.next == 0 {
+			r.full = true
+		}
+	}
+	return nil
+}
+
+func (r *recentTraces) oldestLocked() string {
+	var oldest string
+	var seq uint64
+	for tid, p := range r.pending {
+		if seq == 0 || p.seq < seq {
+			oldest, seq = tid, p.seq
+		}
+	}
+	return oldest
+}
+
+// traces returns the finished traces, most recent first.
+func (r *recentTraces) traces() []Trace {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	n := r.next
+	if r.full {
+		n = len(r.ring)
+	}
+	traces := make([]Trace, 0, n)
+	for i := 1; i <= n; i++ {
+		traces = append(traces, r.ring[(r.next-i+len(r.ring))%len(r.ring)])
+	}
+	return traces
+}
+
+func (r *recentTraces) Shutdown(context.Context) error {
+	return nil
+}
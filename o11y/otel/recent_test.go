@@ -0,0 +1,53 @@
+package otel
+
+import (
+	"context"
+	"encoding/binary"
+	"testing"
+
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+func TestRecentTraces_EvictsOldestPending(t *testing.T) {
+	ctx := context.Background()
+	tid := func(i int) trace.TraceID {
+		var id trace.TraceID
+		binary.BigEndian.PutUint64(id[8:], uint64(i)+1) //nolint:gosec
+		return id
+	}
+	span := func(tr trace.TraceID, id, parent byte) tracetest.SpanStub {
+		ss := tracetest.SpanStub{
+			Name:        string('a' + id),
+			SpanContext: trace.NewSpanContext(trace.SpanContextConfig{TraceID: tr, SpanID: trace.SpanID{id}}),
+		}
+		if parent != 0 {
+			ss.Parent = trace.NewSpanContext(trace.SpanContextConfig{TraceID: tr, SpanID: trace.SpanID{parent}})
+		}
+		return ss
+	}
+
+	r := newRecentTraces(1)
+	// fill the pending traces with traces whose roots never end
+	for i := 0; i < maxPendingTraces; i++ {
+		assert.NilError(t, r.ExportSpans(ctx, tracetest.SpanStubs{span(tid(i), 2, 1)}.Snapshots()))
+	}
+	assert.Check(t, cmp.Len(r.pending, maxPendingTraces))
+
+	tr := tid(maxPendingTraces)
+	assert.NilError(t, r.ExportSpans(ctx, tracetest.SpanStubs{span(tr, 2, 1)}.Snapshots()))
+	assert.NilError(t, r.ExportSpans(ctx, tracetest.SpanStubs{span(tr, 1, 0)}.Snapshots()))
+
+	traces := r.traces()
+	assert.Assert(t, cmp.Len(traces, 1))
+	assert.Check(t, cmp.Equal(traces[0].TraceID, tr.String()))
+	assert.Check(t, cmp.Len(traces[0].Spans, 2), "the new trace keeps its child span")
+
+	assert.Check(t, cmp.Len(r.pending, maxPendingTraces-1))
+	_, ok := r.pending[tid(0).String()]
+	assert.Check(t, !ok, "the oldest pending trace is evicted")
+	_, ok = r.pending[tid(1).String()]
+	assert.Check(t, ok)
+}
@@ -0,0 +1,98 @@
+package otel
+
+import (
+	"regexp"
+)
+
+const redacted = "[REDACTED]"
+
+// cardNumberPattern matches credit card numbers, optionally separated by spaces or dashes. Matches are
+// only redacted if they pass the Luhn check, so that other long numbers, e.g. timestamps, mostly survive.
+var cardNumberPattern = regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`)
+
+// DefaultRedactPatterns returns patterns matching some common secrets, that can be used (and extended)
+// for Config.RedactPatterns. These cover credit card numbers, bearer tokens, JWTs, AWS access key ids
+// and GitHub tokens.
+func DefaultRedactPatterns() []*regexp.Regexp {
+	return []*regexp.Regexp{
+		cardNumberPattern,
+		regexp.MustCompile(`(?i)\bbearer\s+[a-z0-9\-._~+/]+=*`),
+		regexp.MustCompile(`\beyJ[a-zA-Z0-9_-]+\.[a-zA-Z0-9_-]+\.[a-zA-Z0-9_-]+`),
+		regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`),
+		regexp.MustCompile(`\bgh[pousr]_[a-zA-Z0-9]{36,}\b`),
+	}
+}
+
+// redactor scrubs matches of its patterns from string values
+type redactor struct {
+	patterns []*regexp.Regexp
+}
+
+func newRedactor(patterns []*regexp.Regexp) *redactor {
+	if len(patterns) == 0 {
+		return nil
+	}
+	return &redactor{patterns: patterns}
+}
+
+// redact returns val with any matches replaced if it is a string or error, otherwise val is returned as is.
+// Redacted errors are returned as strings.
+func (r *redactor) redact(val any) any {
+	if r == nil {
+		return val
+	}
+	switch v := val.(type) {
+	case string:
+		return r.redactString(v)
+	case error:
+		msg := v.Error()
+		if s := r.redactString(msg); s != msg {
+			return s
+		}
+	}
+	return val
+}
+
+func (r *redactor) redactString(s string) string {
+	for _, p := range r.patterns {
+		// matching first avoids the allocations of replacing, in the common case of no match
+		if !p.MatchString(s) {
+			continue
+		}
+		if p == cardNumberPattern {
+			s = p.ReplaceAllStringFunc(s, redactCardNumber)
+			continue
+		}
+		s = p.ReplaceAllLiteralString(s, redacted)
+	}
+	return s
+}
+
+func redactCardNumber(s string) string {
+	if !luhnValid(s) {
+		return s
+	}
+	return redacted
+}
+
+// luhnValid reports if the digits in s pass the Luhn checksum used by card numbers, ignoring separators
+func luhnValid(s string) bool {
+	sum := 0
+	double := false
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
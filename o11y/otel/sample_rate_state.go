@@ -0,0 +1,73 @@
+package otel
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// sampleRateStateKey is the tracestate key the root sample rate is propagated with, see
+// Config.PropagateSampleRate. e.g. tracestate: o11y=sr:4
+const (
+	sampleRateStateKey    = "o11y"
+	sampleRateStatePrefix = "sr:"
+)
+
+// sampleRateSampler wraps a head sampler, to record the sample rate of kept root spans in the tracestate,
+// so that it is propagated to the rest of the trace, including downstream services.
+type sampleRateSampler struct {
+	sampler sdktrace.Sampler
+}
+
+func (s sampleRateSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	res := s.sampler.ShouldSample(p)
+	if res.Decision != sdktrace.RecordAndSample || trace.SpanContextFromContext(p.ParentContext).IsValid() {
+		return res
+	}
+	for _, a := range res.Attributes {
+		if a.Key != "SampleRate" {
+			continue
+		}
+		val := sampleRateStatePrefix + strconv.FormatInt(a.Value.AsInt64(), 10)
+		if ts, err := res.Tracestate.Insert(sampleRateStateKey, val); err == nil {
+			res.Tracestate = ts
+		}
+	}
+	return res
+}
+
+func (s sampleRateSampler) Description() string {
+	return s.sampler.Description()
+}
+
+// sampleRateProcessor adds the SampleRate propagated in the tracestate to the spans of the trace that
+// do not have a sample rate of their own, e.g. those kept because their parent was.
+type sampleRateProcessor struct{}
+
+func (sampleRateProcessor) OnStart(_ context.Context, s sdktrace.ReadWriteSpan) {
+	if !s.Parent().IsValid() {
+		return
+	}
+	v, ok := strings.CutPrefix(s.SpanContext().TraceState().Get(sampleRateStateKey), sampleRateStatePrefix)
+	if !ok {
+		return
+	}
+	rate, err := strconv.Atoi(v)
+	if err != nil || rate < 1 {
+		return
+	}
+	for _, a := range s.Attributes() {
+		if a.Key == "SampleRate" {
+			return
+		}
+	}
+	s.SetAttributes(attribute.Int("SampleRate", rate))
+}
+
+func (sampleRateProcessor) OnEnd(sdktrace.ReadOnlySpan)      {}
+func (sampleRateProcessor) Shutdown(context.Context) error   { return nil }
+func (sampleRateProcessor) ForceFlush(context.Context) error { return nil }
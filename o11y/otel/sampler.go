@@ -1,31 +1,182 @@
 package otel
 
 import (
+	"fmt"
 	"hash/crc32"
 	"math"
+	"sync"
+	"sync/atomic"
 
+	"go.opentelemetry.io/otel/attribute"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// CanaryField is the global field (see AddGlobalField) that marks this instance as a canary. When it is
+// set to true, or the string "true", every span is kept, by both the SampleTraces sampler and any head
+// Sampler, so that canary deployments are fully observed. Global fields are set on each span as it
+// starts, before any sampling decision is made, so they are always visible to the samplers.
+const CanaryField = "deploy.canary"
+
 type deterministicSampler struct {
-	sampleKeyFunc func(map[string]any) string
-	sampleRates   map[string]uint
+	sampleKeyFunc   func(map[string]any) string
+	sampleKeyFields []string
+	byTraceID       bool
+	seed            string
+
+	mu          sync.RWMutex
+	sampleRates map[string]uint
+}
+
+// SamplerOption configures a sampler returned by NewDeterministicSampler.
+type SamplerOption func(*deterministicSampler)
+
+// WithSamplerSeed mixes seed into the hash the keep decision is made from, see Config.SampleSeed.
+func WithSamplerSeed(seed string) SamplerOption {
+	return func(s *deterministicSampler) {
+		s.seed = seed
+	}
+}
+
+// NewDeterministicSampler returns the SampleTraces sampler as a head sampler, for use in other otel
+// setups. The sample key is given by keyFunc, from the span name and attributes in the same way as
+// Config.SampleKeyFunc, and spans with a key in rates are kept at that rate, with the SampleRate
+// attribute added. Spans with any other key are kept. Every span is kept on canary instances.
+//
+// Unlike SampleTraces, which samples spans as they are exported, the decision is made as the span
+// starts, so keyFunc only sees the start attributes (see o11y.WithStartFields), and it is made from
+// the trace id. It is typically wrapped in sdktrace.ParentBased, so children follow their root.
+func NewDeterministicSampler(keyFunc func(map[string]any) string, rates map[string]uint,
+	opts ...SamplerOption) sdktrace.Sampler {
+	s := &deterministicSampler{sampleKeyFunc: keyFunc}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.updateRates(rates)
+	return s
+}
+
+// ShouldSample makes the head sampling decision, see NewDeterministicSampler.
+func (s *deterministicSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	keep, rate := s.decide(p.Name, func() []attribute.KeyValue { return p.Attributes }, p.TraceID.String)
+	return headSamplingResult(p, keep, rate)
+}
+
+func (s *deterministicSampler) Description() string {
+	return "DeterministicSampler"
 }
 
 // shouldSample means should sample in, returning true if the span should be sampled in (kept)
-func (s deterministicSampler) shouldSample(p sdktrace.ReadOnlySpan) (bool, uint) {
-	fields := map[string]any{}
-	for _, attr := range p.Attributes() {
-		fields[string(attr.Key)] = attr.Value.AsInterface()
+func (s *deterministicSampler) shouldSample(p sdktrace.ReadOnlySpan) (bool, uint) {
+	return s.decide(p.Name(), p.Attributes, func() string { return s.determinant(p) })
+}
+
+// decide makes the keep decision, only reading the attributes and determinant if they are needed
+func (s *deterministicSampler) decide(name string, attrs func() []attribute.KeyValue,
+	determinant func() string) (bool, uint) {
+	if globalFields.isCanary() {
+		return true, 1
 	}
-	fields["name"] = p.Name()
 
-	key := s.sampleKeyFunc(fields)
+	key := s.sampleKeyFunc(s.fieldsOf(name, attrs))
+	s.mu.RLock()
 	rate, ok := s.sampleRates[key] // no rate found means keep
+	s.mu.RUnlock()
 	if !ok {
 		return true, 1 // and is a sample rate of 1/1
 	}
-	return shouldKeep(p.SpanContext().SpanID().String(), rate), rate
+	return shouldKeepSeeded(determinant(), s.seed, rate), rate
+}
+
+// rates returns a copy of the sample rates.
+func (s *deterministicSampler) rates() map[string]uint {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rates := make(map[string]uint, len(s.sampleRates))
+	for k, v := range s.sampleRates {
+		rates[k] = v
+	}
+	return rates
+}
+
+// updateRates replaces the sample rates with a copy of rates.
+func (s *deterministicSampler) updateRates(rates map[string]uint) {
+	c := make(map[string]uint, len(rates))
+	for k, v := range rates {
+		c[k] = v
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sampleRates = c
+}
+
+// determinant returns the id that the keep decision is made from.
+func (s *deterministicSampler) determinant(p sdktrace.ReadOnlySpan) string {
+	if s.byTraceID {
+		return p.SpanContext().TraceID().String()
+	}
+	return p.SpanContext().SpanID().String()
+}
+
+// determinantAttr describes the determinant as a debug aid, as the kind of id used and its hash,
+// e.g. "trace_id:1a2b3c4d".
+func (s *deterministicSampler) determinantAttr(p sdktrace.ReadOnlySpan) attribute.KeyValue {
+	kind := "span_id"
+	if s.byTraceID {
+		kind = "trace_id"
+	}
+	v := crc32.ChecksumIEEE([]byte(s.determinant(p)))
+	return attribute.String("sampling.determinant", fmt.Sprintf("%s:%08x", kind, v))
+}
+
+// fieldsOf returns the span fields for the sample key func, restricted to the sampleKeyFields if set.
+// The span name is always available as span.name. It is also available as name, unless the span has
+// a name attribute of its own, which takes precedence. The attributes are not read at all if only
+// span.name is needed.
+func (s *deterministicSampler) fieldsOf(name string, attrs func() []attribute.KeyValue) map[string]any {
+	if s.sampleKeyFields == nil {
+		fields := map[string]any{"name": name}
+		for _, attr := range attrs() {
+			fields[string(attr.Key)] = attr.Value.AsInterface()
+		}
+		fields["span.name"] = name
+		return fields
+	}
+
+	fields := make(map[string]any, len(s.sampleKeyFields))
+	needAttrs := false
+	for _, k := range s.sampleKeyFields {
+		switch k {
+		case "span.name":
+			fields[k] = name
+		case "name":
+			fields[k] = name
+			needAttrs = true
+		default:
+			needAttrs = true
+		}
+	}
+	if !needAttrs {
+		return fields
+	}
+	for _, attr := range attrs() {
+		k := string(attr.Key)
+		if k != "span.name" && s.wantsField(k) {
+			fields[k] = attr.Value.AsInterface()
+		}
+	}
+	return fields
+}
+
+func (s *deterministicSampler) wantsField(key string) bool {
+	for _, k := range s.sampleKeyFields {
+		if k == key {
+			return true
+		}
+	}
+	return false
 }
 
 // shouldKeep deterministically decides whether to sample. True means keep, false means drop
@@ -42,3 +193,205 @@ func shouldKeep(determinant string, rate uint) bool {
 
 	return v < threshold
 }
+
+// shouldKeepSeeded is shouldKeep with the seed mixed into the hash. With no seed it is identical to
+// shouldKeep. The checksum is mixed with the seed through the murmur3 finalizer, since crc32 alone is
+// linear, so decisions with different seeds would be correlated rather than independent.
+func shouldKeepSeeded(determinant, seed string, rate uint) bool {
+	if seed == "" {
+		return shouldKeep(determinant, rate)
+	}
+	if rate < 2 {
+		return true
+	}
+	if rate > math.MaxUint32 {
+		rate = math.MaxUint32
+	}
+
+	threshold := math.MaxUint32 / uint32(rate) //nolint:gosec
+	v := crc32.ChecksumIEEE([]byte(determinant)) ^ crc32.ChecksumIEEE([]byte(seed))
+	v ^= v >> 16
+	v *= 0x85ebca6b
+	v ^= v >> 13
+	v *= 0xc2b2ae35
+	v ^= v >> 16
+
+	return v < threshold
+}
+
+// keepField decides whether a field should be recorded on this span, according to any sample
+// rate configured for the key in SampledFields.
+func (s *span) keepField(key string) bool {
+	rate, ok := s.sampledFields[key]
+	if !ok {
+		return true
+	}
+	return shouldKeep(s.span.SpanContext().SpanID().String(), rate)
+}
+
+// NewRatioSampler returns a head sampler that keeps the given fraction of traces, as decided by the
+// otel TraceIDRatioBased sampler. Kept spans have the SampleRate attribute added, in the same way as
+// the deterministic sampler, so that downstream count reconstruction works uniformly.
+func NewRatioSampler(fraction float64) sdktrace.Sampler {
+	rate := 0
+	switch {
+	case fraction >= 1:
+		rate = 1
+	case fraction > 0:
+		rate = int(math.Round(1 / fraction))
+	}
+	return ratioSampler{
+		sampler:  sdktrace.TraceIDRatioBased(fraction),
+		fraction: fraction,
+		rate:     rate,
+	}
+}
+
+type ratioSampler struct {
+	sampler  sdktrace.Sampler
+	fraction float64
+	rate     int
+}
+
+func (s ratioSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	res := s.sampler.ShouldSample(p)
+	if res.Decision == sdktrace.RecordAndSample {
+		res.Attributes = append(res.Attributes, attribute.Int("SampleRate", s.rate))
+	}
+	return res
+}
+
+func (s ratioSampler) Description() string {
+	return fmt.Sprintf("RatioSampler{%g}", s.fraction)
+}
+
+// ThresholdOp is a comparison used by a ThresholdRule
+type ThresholdOp string
+
+const (
+	GreaterThan        ThresholdOp = ">"
+	GreaterThanOrEqual ThresholdOp = ">="
+	LessThan           ThresholdOp = "<"
+	LessThanOrEqual    ThresholdOp = "<="
+)
+
+// ThresholdRule applies the sample Rate to spans where the numeric Field compares to Value using Op.
+// For example, to keep every large request:
+//
+//	ThresholdRule{Field: "http.request_content_length", Op: GreaterThan, Value: 1_000_000, Rate: 1}
+type ThresholdRule struct {
+	Field string
+	Op    ThresholdOp
+	Value float64
+	Rate  uint
+}
+
+func (r ThresholdRule) matches(v float64) bool {
+	switch r.Op {
+	case GreaterThan:
+		return v > r.Value
+	case GreaterThanOrEqual:
+		return v >= r.Value
+	case LessThan:
+		return v < r.Value
+	case LessThanOrEqual:
+		return v <= r.Value
+	}
+	return false
+}
+
+// NewThresholdSampler returns a head sampler that picks the sample rate from the first rule that
+// matches the span, falling back to defaultRate if none match. The keep decision is made
+// deterministically from the trace ID, and kept spans have the SampleRate attribute added.
+//
+// Since the decision is made as the span starts, the rule fields must be set at span start, via
+// o11y.WithStartFields, for them to be seen by the sampler. Fields added to the span after it has
+// started are not visible. Child spans do not usually carry these fields, so this sampler is
+// typically wrapped in sdktrace.ParentBased, so that children follow the decision of their root.
+func NewThresholdSampler(defaultRate uint, rules ...ThresholdRule) sdktrace.Sampler {
+	return thresholdSampler{
+		defaultRate: defaultRate,
+		rules:       rules,
+	}
+}
+
+type thresholdSampler struct {
+	defaultRate uint
+	rules       []ThresholdRule
+}
+
+func (s thresholdSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	rate := s.rate(p.Attributes)
+	return headSamplingResult(p, shouldKeep(p.TraceID.String(), rate), rate)
+}
+
+func (s thresholdSampler) rate(attrs []attribute.KeyValue) uint {
+	for _, r := range s.rules {
+		for _, a := range attrs {
+			if string(a.Key) != r.Field {
+				continue
+			}
+			var v float64
+			switch a.Value.Type() {
+			case attribute.INT64:
+				v = float64(a.Value.AsInt64())
+			case attribute.FLOAT64:
+				v = a.Value.AsFloat64()
+			default:
+				continue
+			}
+			if r.matches(v) {
+				return r.Rate
+			}
+		}
+	}
+	return s.defaultRate
+}
+
+func (s thresholdSampler) Description() string {
+	return fmt.Sprintf("ThresholdSampler{default:%d,rules:%d}", s.defaultRate, len(s.rules))
+}
+
+// canarySampler wraps a head sampler, to keep all spans if the canary global field is set.
+// N.B. head samplers run before the global fields are added to the span, so they are checked directly.
+type canarySampler struct {
+	sampler sdktrace.Sampler
+}
+
+func (s canarySampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	if globalFields.isCanary() {
+		return headSamplingResult(p, true, 1)
+	}
+	return s.sampler.ShouldSample(p)
+}
+
+func (s canarySampler) Description() string {
+	return s.sampler.Description()
+}
+
+// headSamplingResult returns the result for a head sampler keep decision, adding the SampleRate
+// attribute to kept spans.
+func headSamplingResult(p sdktrace.SamplingParameters, keep bool, rate uint) sdktrace.SamplingResult {
+	ts := trace.SpanContextFromContext(p.ParentContext).TraceState()
+	if !keep {
+		return sdktrace.SamplingResult{Decision: sdktrace.Drop, Tracestate: ts}
+	}
+	return sdktrace.SamplingResult{
+		Decision:   sdktrace.RecordAndSample,
+		Attributes: []attribute.KeyValue{attribute.Int("SampleRate", int(rate))}, //nolint:gosec
+		Tracestate: ts,
+	}
+}
+
+// logSampler keeps every rate'th Log event.
+type logSampler struct {
+	rate uint64
+	n    atomic.Uint64
+}
+
+func (l *logSampler) keep() bool {
+	if l == nil || l.rate < 2 {
+		return true
+	}
+	return l.n.Add(1)%l.rate == 1
+}
@@ -0,0 +1,282 @@
+package otel
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+func TestCanarySampling(t *testing.T) {
+	t.Run("head sampler", func(t *testing.T) {
+		orig := globalFields
+		t.Cleanup(func() { globalFields = orig })
+
+		s := canarySampler{sampler: sdktrace.NeverSample()}
+		p := sdktrace.SamplingParameters{ParentContext: context.Background(), Name: "span"}
+		assert.Check(t, cmp.Equal(s.ShouldSample(p).Decision, sdktrace.Drop))
+
+		globalFields.addField(CanaryField, true)
+		res := s.ShouldSample(p)
+		assert.Check(t, cmp.Equal(res.Decision, sdktrace.RecordAndSample))
+		assert.Assert(t, cmp.Len(res.Attributes, 1))
+		assert.Check(t, cmp.Equal(res.Attributes[0], attribute.Int("SampleRate", 1)))
+	})
+
+	t.Run("export sampler", func(t *testing.T) {
+		orig := globalFields
+		t.Cleanup(func() { globalFields = orig })
+
+		s := deterministicSampler{
+			sampleKeyFunc: func(map[string]any) string { return "all" },
+			sampleRates:   map[string]uint{"all": 1e9},
+		}
+		span := tracetest.SpanStub{Name: "span"}.Snapshot()
+		keep, _ := s.shouldSample(span)
+		assert.Check(t, !keep)
+
+		globalFields.addField(CanaryField, true)
+		keep, rate := s.shouldSample(span)
+		assert.Check(t, keep)
+		assert.Check(t, cmp.Equal(rate, uint(1)))
+	})
+
+	t.Run("field values", func(t *testing.T) {
+		orig := globalFields
+		t.Cleanup(func() { globalFields = orig })
+
+		globalFields = Annotator{}
+		globalFields.addField(CanaryField, "true")
+		assert.Check(t, globalFields.isCanary())
+		globalFields.addField(CanaryField, false)
+		assert.Check(t, !globalFields.isCanary())
+		globalFields.addField(CanaryField, "yes")
+		assert.Check(t, !globalFields.isCanary())
+		globalFields.addField(CanaryField, true)
+		globalFields.addField("other", "value")
+		assert.Check(t, globalFields.isCanary())
+	})
+}
+
+func TestDeterministicSampler_SampleKeyFields(t *testing.T) {
+	span := tracetest.SpanStub{
+		Name: "span",
+		Attributes: []attribute.KeyValue{
+			attribute.String("http.route", "/api"),
+			attribute.String("other", "value"),
+		},
+	}.Snapshot()
+
+	tests := []struct {
+		name   string
+		fields []string
+		want   map[string]any
+	}{
+		{
+			name:   "all",
+			fields: nil,
+			want:   map[string]any{"name": "span", "span.name": "span", "http.route": "/api", "other": "value"},
+		},
+		{
+			name:   "span name only",
+			fields: []string{"span.name"},
+			want:   map[string]any{"span.name": "span"},
+		},
+		{
+			name:   "some",
+			fields: []string{"name", "http.route", "missing"},
+			want:   map[string]any{"name": "span", "http.route": "/api"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := deterministicSampler{sampleKeyFields: tt.fields}
+			assert.Check(t, cmp.DeepEqual(s.fieldsOf(span.Name(), span.Attributes), tt.want))
+		})
+	}
+}
+
+func TestDeterministicSampler_NameAttribute(t *testing.T) {
+	span := tracetest.SpanStub{
+		Name:       "span",
+		Attributes: []attribute.KeyValue{attribute.String("name", "user name")},
+	}.Snapshot()
+
+	for _, fields := range [][]string{nil, {"name", "span.name"}} {
+		s := deterministicSampler{sampleKeyFields: fields}
+		got := s.fieldsOf(span.Name(), span.Attributes)
+		assert.Check(t, cmp.Equal(got["name"], "user name"))
+		assert.Check(t, cmp.Equal(got["span.name"], "span"))
+	}
+}
+
+func BenchmarkShouldSample(b *testing.B) {
+	attrs := make([]attribute.KeyValue, 0, 20)
+	for i := 0; i < 20; i++ {
+		attrs = append(attrs, attribute.Int(fmt.Sprintf("field_%d", i), i))
+	}
+	span := tracetest.SpanStub{Name: "span", Attributes: attrs}.Snapshot()
+
+	for _, bb := range []struct {
+		name   string
+		fields []string
+	}{
+		{name: "all fields"},
+		{name: "span name only", fields: []string{"span.name"}},
+	} {
+		s := deterministicSampler{
+			sampleKeyFunc:   func(f map[string]any) string { return f["span.name"].(string) },
+			sampleRates:     map[string]uint{"span": 10},
+			sampleKeyFields: bb.fields,
+		}
+		b.Run(bb.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				s.shouldSample(span)
+			}
+		})
+	}
+}
+
+func TestCompositeSampler(t *testing.T) {
+	keepSlow := SubSamplerFunc(func(p sdktrace.SamplingParameters) (SampleDecision, uint) {
+		for _, a := range p.Attributes {
+			if a.Key == "slow" && a.Value.AsBool() {
+				return SampleKeep, 1
+			}
+		}
+		return SampleUndecided, 0
+	})
+	dropHealth := SubSamplerFunc(func(p sdktrace.SamplingParameters) (SampleDecision, uint) {
+		if p.Name == "healthcheck" {
+			return SampleDrop, 0
+		}
+		return SampleUndecided, 0
+	})
+	undecided := SubSamplerFunc(func(sdktrace.SamplingParameters) (SampleDecision, uint) {
+		return SampleUndecided, 0
+	})
+
+	params := func(name string, attrs ...attribute.KeyValue) sdktrace.SamplingParameters {
+		return sdktrace.SamplingParameters{ParentContext: context.Background(), Name: name, Attributes: attrs}
+	}
+
+	t.Run("first decision wins", func(t *testing.T) {
+		s := NewCompositeSampler(keepSlow, dropHealth, RateSubSampler(1e9))
+
+		res := s.ShouldSample(params("healthcheck", attribute.Bool("slow", true)))
+		assert.Check(t, cmp.Equal(res.Decision, sdktrace.RecordAndSample))
+		assert.Assert(t, cmp.Len(res.Attributes, 1))
+		assert.Check(t, cmp.Equal(res.Attributes[0], attribute.Int("SampleRate", 1)))
+
+		res = s.ShouldSample(params("healthcheck"))
+		assert.Check(t, cmp.Equal(res.Decision, sdktrace.Drop))
+	})
+
+	t.Run("rate", func(t *testing.T) {
+		s := NewCompositeSampler(undecided, RateSubSampler(2))
+		kept := 0
+		for n := 0; n < 1000; n++ {
+			p := params("span")
+			p.TraceID = [16]byte{byte(n), byte(n >> 8)}
+			res := s.ShouldSample(p)
+			if res.Decision == sdktrace.RecordAndSample {
+				kept++
+				assert.Check(t, cmp.Equal(res.Attributes[0], attribute.Int("SampleRate", 2)))
+			}
+		}
+		assert.Check(t, kept > 400 && kept < 600, "kept %d", kept)
+	})
+
+	t.Run("default", func(t *testing.T) {
+		s := NewCompositeSampler(undecided)
+		res := s.ShouldSample(params("span"))
+		assert.Check(t, cmp.Equal(res.Decision, sdktrace.RecordAndSample))
+		assert.Check(t, cmp.Equal(res.Attributes[0], attribute.Int("SampleRate", 1)))
+	})
+}
+
+func TestNewDeterministicSampler(t *testing.T) {
+	s := NewDeterministicSampler(func(fields map[string]any) string {
+		return fmt.Sprintf("%v", fields["span.name"])
+	}, map[string]uint{"noisy": 4})
+	assert.Check(t, cmp.Equal(s.Description(), "DeterministicSampler"))
+
+	kept := 0
+	for i := 0; i < 1000; i++ {
+		p := sdktrace.SamplingParameters{
+			ParentContext: context.Background(),
+			TraceID:       trace.TraceID{byte(i), byte(i >> 8), 1},
+			Name:          "noisy",
+		}
+		res := s.ShouldSample(p)
+		if res.Decision == sdktrace.RecordAndSample {
+			kept++
+			assert.Assert(t, cmp.Len(res.Attributes, 1))
+			assert.Check(t, cmp.Equal(res.Attributes[0], attribute.Int("SampleRate", 4)))
+		}
+	}
+	assert.Check(t, kept > 150 && kept < 350, "unexpected number of spans kept: %d", kept)
+
+	res := s.ShouldSample(sdktrace.SamplingParameters{ParentContext: context.Background(), Name: "other"})
+	assert.Check(t, cmp.Equal(res.Decision, sdktrace.RecordAndSample))
+}
+
+func TestShouldKeepSeeded(t *testing.T) {
+	keep := func(seed string) []bool {
+		decisions := make([]bool, 2000)
+		for i := range decisions {
+			decisions[i] = shouldKeepSeeded(fmt.Sprintf("%032x", i), seed, 2)
+		}
+		return decisions
+	}
+	count := func(decisions []bool) (n int) {
+		for _, d := range decisions {
+			if d {
+				n++
+			}
+		}
+		return n
+	}
+
+	t.Run("no seed is unchanged", func(t *testing.T) {
+		for i, d := range keep("") {
+			assert.Check(t, cmp.Equal(d, shouldKeep(fmt.Sprintf("%032x", i), 2)))
+		}
+	})
+
+	t.Run("same seed makes the same decisions", func(t *testing.T) {
+		assert.Check(t, cmp.DeepEqual(keep("experiment-a"), keep("experiment-a")))
+	})
+
+	t.Run("different seeds make independent decisions", func(t *testing.T) {
+		a, b := keep("experiment-a"), keep("experiment-b")
+		both := 0
+		for i := range a {
+			if a[i] && b[i] {
+				both++
+			}
+		}
+		// each keeps about half, and independently about a quarter are kept by both
+		assert.Check(t, count(a) > 900 && count(a) < 1100, count(a))
+		assert.Check(t, count(b) > 900 && count(b) < 1100, count(b))
+		assert.Check(t, both > 400 && both < 600, both)
+	})
+
+	t.Run("sampler option", func(t *testing.T) {
+		s := NewDeterministicSampler(func(map[string]any) string { return "key" },
+			map[string]uint{"key": 2}, WithSamplerSeed("experiment-a"))
+		for i := 0; i < 100; i++ {
+			tid := trace.TraceID{byte(i), 1}
+			res := s.ShouldSample(sdktrace.SamplingParameters{ParentContext: context.Background(), TraceID: tid})
+			assert.Check(t, cmp.Equal(res.Decision == sdktrace.RecordAndSample,
+				shouldKeepSeeded(tid.String(), "experiment-a", 2)))
+		}
+	})
+}
@@ -0,0 +1,150 @@
+package otel
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	defaultBatchByTraceTimeout  = 30 * time.Second
+	defaultBatchByTraceMaxSpans = 10000
+)
+
+// traceBatcher is an exporter that holds back the spans of each trace until its local root span ends,
+// i.e. a span with no parent, or a remote parent, then exports the whole trace to next in one call.
+type traceBatcher struct {
+	next     sdktrace.SpanExporter
+	timeout  time.Duration
+	maxSpans int
+	now      func() time.Time
+
+	mu      sync.Mutex
+	pending map[trace.TraceID]*pendingTrace
+	spans   int
+
+	stopOnce sync.Once
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+type pendingTrace struct {
+	started time.Time
+	spans   []sdktrace.ReadOnlySpan
+}
+
+func newTraceBatcher(next sdktrace.SpanExporter, timeout time.Duration, maxSpans int) *traceBatcher {
+	if timeout <= 0 {
+		timeout = defaultBatchByTraceTimeout
+	}
+	if maxSpans <= 0 {
+		maxSpans = defaultBatchByTraceMaxSpans
+	}
+	b := &traceBatcher{
+		next:     next,
+		timeout:  timeout,
+		maxSpans: maxSpans,
+		now:      time.Now,
+		pending:  map[trace.TraceID]*pendingTrace{},
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go b.expire()
+	return b
+}
+
+func (b *traceBatcher) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	b.mu.Lock()
+	var ready []sdktrace.ReadOnlySpan
+	for _, s := range spans {
+		tid := s.SpanContext().TraceID()
+		p := b.pending[tid]
+		if !s.Parent().IsValid() || s.Parent().IsRemote() {
+			if p != nil {
+				ready = append(ready, b.removeLocked(tid)...)
+			}
+			ready = append(ready, s)
+			continue
+		}
+		if p == nil {
+			p = &pendingTrace{started: b.now()}
+			b.pending[tid] = p
+		}
+		p.spans = append(p.spans, s)
+		b.spans++
+	}
+	for b.spans > b.maxSpans {
+		ready = append(ready, b.removeLocked(b.oldestLocked())...)
+	}
+	b.mu.Unlock()
+
+	if len(ready) == 0 {
+		return nil
+	}
+	return b.next.ExportSpans(ctx, ready)
+}
+
+// expire periodically exports the traces that have been pending for longer than the timeout
+func (b *traceBatcher) expire() {
+	defer close(b.done)
+
+	t := time.NewTicker(b.timeout / 2)
+	defer t.Stop()
+	for {
+		select {
+		case <-b.stop:
+			return
+		case <-t.C:
+			_ = b.flush(context.Background(), b.now().Add(-b.timeout))
+		}
+	}
+}
+
+// flush exports the traces that started pending before the cutoff
+func (b *traceBatcher) flush(ctx context.Context, cutoff time.Time) error {
+	b.mu.Lock()
+	var ready []sdktrace.ReadOnlySpan
+	for tid, p := range b.pending {
+		if p.started.Before(cutoff) {
+			ready = append(ready, b.removeLocked(tid)...)
+		}
+	}
+	b.mu.Unlock()
+
+	if len(ready) == 0 {
+		return nil
+	}
+	return b.next.ExportSpans(ctx, ready)
+}
+
+func (b *traceBatcher) removeLocked(tid trace.TraceID) []sdktrace.ReadOnlySpan {
+	p := b.pending[tid]
+	delete(b.pending, tid)
+	b.spans -= len(p.spans)
+	return p.spans
+}
+
+func (b *traceBatcher) oldestLocked() trace.TraceID {
+	var oldest trace.TraceID
+	var started time.Time
+	for tid, p := range b.pending {
+		if started.IsZero() || p.started.Before(started) {
+			oldest, started = tid, p.started
+		}
+	}
+	return oldest
+}
+
+// Shutdown exports all the pending traces, before shutting down next.
+func (b *traceBatcher) Shutdown(ctx context.Context) error {
+	b.stopOnce.Do(func() { close(b.stop) })
+	<-b.done
+
+	// a cutoff in the future flushes every pending trace, and the next exporter is shut down even if
+	// that fails, e.g. if the collector is down at exit
+	return errors.Join(b.flush(ctx, b.now().Add(time.Hour)), b.next.Shutdown(ctx))
+}
@@ -0,0 +1,111 @@
+package otel
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+func TestTraceBatcher(t *testing.T) {
+	ctx := context.Background()
+	tid := func(b byte) trace.TraceID { return trace.TraceID{b} }
+	span := func(tr trace.TraceID, id, parent byte) tracetest.SpanStub {
+		ss := tracetest.SpanStub{
+			Name:        string('a' + id),
+			SpanContext: trace.NewSpanContext(trace.SpanContextConfig{TraceID: tr, SpanID: trace.SpanID{id}}),
+		}
+		if parent != 0 {
+			ss.Parent = trace.NewSpanContext(trace.SpanContextConfig{TraceID: tr, SpanID: trace.SpanID{parent}})
+		}
+		return ss
+	}
+	names := func(exp *tracetest.InMemoryExporter) []string {
+		var n []string
+		for _, s := range exp.GetSpans() {
+			n = append(n, s.Name)
+		}
+		exp.Reset()
+		return n
+	}
+
+	t.Run("exports the trace when the root ends", func(t *testing.T) {
+		exp := tracetest.NewInMemoryExporter()
+		b := newTraceBatcher(keepOnShutdown{exp}, time.Minute, 0)
+
+		assert.NilError(t, b.ExportSpans(ctx, tracetest.SpanStubs{span(tid(1), 3, 2)}.Snapshots()))
+		assert.NilError(t, b.ExportSpans(ctx, tracetest.SpanStubs{span(tid(1), 2, 1), span(tid(2), 5, 4)}.Snapshots()))
+		assert.Check(t, cmp.Len(names(exp), 0))
+
+		assert.NilError(t, b.ExportSpans(ctx, tracetest.SpanStubs{span(tid(1), 1, 0)}.Snapshots()))
+		assert.Check(t, cmp.DeepEqual(names(exp), []string{"d", "c", "b"}))
+
+		assert.NilError(t, b.Shutdown(ctx))
+		assert.Check(t, cmp.DeepEqual(names(exp), []string{"f"}), "pending traces are exported on shutdown")
+	})
+
+	t.Run("exports the oldest traces over the max spans", func(t *testing.T) {
+		exp := tracetest.NewInMemoryExporter()
+		b := newTraceBatcher(exp, time.Minute, 2)
+		now := time.Now()
+		b.now = func() time.Time { return now }
+
+		assert.NilError(t, b.ExportSpans(ctx, tracetest.SpanStubs{span(tid(1), 2, 1)}.Snapshots()))
+		now = now.Add(time.Second)
+		assert.NilError(t, b.ExportSpans(ctx, tracetest.SpanStubs{span(tid(2), 4, 3), span(tid(2), 5, 3)}.Snapshots()))
+		assert.Check(t, cmp.DeepEqual(names(exp), []string{"c"}))
+		assert.NilError(t, b.Shutdown(ctx))
+	})
+
+	t.Run("exports traces pending longer than the timeout", func(t *testing.T) {
+		exp := tracetest.NewInMemoryExporter()
+		b := newTraceBatcher(exp, 10*time.Millisecond, 0)
+
+		assert.NilError(t, b.ExportSpans(ctx, tracetest.SpanStubs{span(tid(1), 2, 1)}.Snapshots()))
+		deadline := time.Now().Add(5 * time.Second)
+		for len(exp.GetSpans()) == 0 && time.Now().Before(deadline) {
+			time.Sleep(5 * time.Millisecond)
+		}
+		assert.Check(t, cmp.DeepEqual(names(exp), []string{"c"}))
+		assert.NilError(t, b.Shutdown(ctx))
+	})
+
+	t.Run("shuts down the exporter when the final flush fails", func(t *testing.T) {
+		exp := &failingExporter{}
+		b := newTraceBatcher(exp, time.Minute, 0)
+
+		assert.NilError(t, b.ExportSpans(ctx, tracetest.SpanStubs{span(tid(1), 2, 1)}.Snapshots()))
+		assert.Check(t, cmp.ErrorIs(b.Shutdown(ctx), errExport))
+		assert.Check(t, exp.shutdown)
+	})
+}
+
+var errExport = errors.New("collector down")
+
+type failingExporter struct {
+	shutdown bool
+}
+
+func (*failingExporter) ExportSpans(context.Context, []sdktrace.ReadOnlySpan) error {
+	return errExport
+}
+
+func (e *failingExporter) Shutdown(context.Context) error {
+	e.shutdown = true
+	return nil
+}
+
+// keepOnShutdown stops the in memory exporter from discarding its spans on shutdown
+type keepOnShutdown struct {
+	*tracetest.InMemoryExporter
+}
+
+func (keepOnShutdown) Shutdown(context.Context) error {
+	return nil
+}
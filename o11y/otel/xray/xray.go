@@ -0,0 +1,31 @@
+/*
+Package xray provides the AWS X-Ray trace id format and X-Amzn-Trace-Id header propagation for the
+otel provider, so that traces interoperate with services instrumented for X-Ray. It uses the otel
+contrib X-Ray id generator and propagator.
+
+X-Ray accepts otel spans with X-Ray formatted trace ids via the awsxray exporter of an OpenTelemetry
+collector (e.g. the AWS Distro for OpenTelemetry), so spans are still exported with otel.Config
+GrpcHostAndPort, pointing at that collector. This package has no AWS SDK dependencies.
+*/
+package xray
+
+import (
+	"go.opentelemetry.io/contrib/propagators/aws/xray"
+	"go.opentelemetry.io/otel/propagation"
+
+	"github.com/circleci/ex/o11y/otel"
+)
+
+// TraceHeader is the header X-Ray uses to propagate traces, e.g.
+//
+//	X-Amzn-Trace-Id: Root=1-5759e988-bd862e3fe1be46a994272793;Parent=53995c3f42cd8ad8;Sampled=1
+const TraceHeader = "X-Amzn-Trace-Id"
+
+// Configure returns a copy of conf using X-Ray compatible trace ids, which start with the epoch seconds
+// of the trace start, and propagating the TraceHeader, in addition to the default propagators.
+// It is applied by the o11y config package when OtelConfig.XRay is set.
+func Configure(conf otel.Config) otel.Config {
+	conf.IDGenerator = xray.NewIDGenerator()
+	conf.Propagators = append(append([]propagation.TextMapPropagator{}, conf.Propagators...), xray.Propagator{})
+	return conf
+}
@@ -0,0 +1,54 @@
+package xray
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+
+	"github.com/circleci/ex/internal/syncbuffer"
+	"github.com/circleci/ex/o11y"
+	"github.com/circleci/ex/o11y/otel"
+)
+
+func TestConfigure(t *testing.T) {
+	op, err := otel.New(Configure(otel.Config{Writer: &syncbuffer.SyncBuffer{}}))
+	assert.NilError(t, err)
+	ctx := o11y.WithProvider(context.Background(), op)
+	defer op.Close(ctx)
+
+	before := time.Now().Unix()
+	ctx, span := o11y.StartSpan(ctx, "span")
+	defer span.End()
+
+	// the trace id starts with the epoch seconds
+	epoch := span.Context().TraceID[:8]
+	secs, err := strconv.ParseInt(epoch, 16, 64)
+	assert.NilError(t, err)
+	assert.Check(t, secs >= before && secs <= time.Now().Unix(), secs)
+
+	headers := op.Helpers().ExtractPropagation(ctx).Headers
+	h := headers.Get(TraceHeader)
+	assert.Check(t, cmp.Contains(h, "Root=1-"+epoch+"-"))
+	assert.Check(t, headers.Get("traceparent") != "", "w3c propagation should remain")
+}
+
+func TestConfigure_Extract(t *testing.T) {
+	const header = "Root=1-5759e988-bd862e3fe1be46a994272793;Parent=53995c3f42cd8ad8;Sampled=1"
+
+	op, err := otel.New(Configure(otel.Config{Writer: &syncbuffer.SyncBuffer{}}))
+	assert.NilError(t, err)
+	ctx := o11y.WithProvider(context.Background(), op)
+	defer op.Close(ctx)
+
+	h := http.Header{}
+	h.Set(TraceHeader, header)
+	_, span := op.Helpers().InjectPropagation(ctx, o11y.PropagationContextFromHeader(h))
+	defer span.End()
+
+	assert.Check(t, cmp.Equal(span.Context().TraceID, "5759e988bd862e3fe1be46a994272793"))
+}
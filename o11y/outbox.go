@@ -0,0 +1,24 @@
+package o11y
+
+import (
+	"context"
+	"time"
+)
+
+// RecordOutboxPublish adds the standard outbox.event_type and outbox.lag_ms fields to the currently
+// active span, when publishing an event from a transactional outbox. The lag is the time from createdAt,
+// when the event was written in its originating transaction, until now, and shows how stale published
+// events are. It is omitted if createdAt is the zero time.
+//
+// To link the publish span to the originating transaction span, store the SpanContext of the transaction
+// with the event, and publish from a context returned by ContextFrom, so both are in the same trace.
+func RecordOutboxPublish(ctx context.Context, eventType string, createdAt time.Time) {
+	span := FromContext(ctx).GetSpan(ctx)
+	if span == nil {
+		return
+	}
+	span.AddRawField("outbox.event_type", eventType)
+	if !createdAt.IsZero() {
+		span.AddRawField("outbox.lag_ms", float64(time.Since(createdAt))/float64(time.Millisecond))
+	}
+}
@@ -0,0 +1,38 @@
+package o11y
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+func TestRecordOutboxPublish(t *testing.T) {
+	t.Run("without provider", func(t *testing.T) {
+		RecordOutboxPublish(context.Background(), "build.created", time.Now())
+	})
+
+	t.Run("adds fields", func(t *testing.T) {
+		ctx := WithProvider(context.Background(), &fakeProvider{})
+		ctx, span := StartSpan(ctx, "publish")
+
+		RecordOutboxPublish(ctx, "build.created", time.Now().Add(-time.Minute))
+		fields := span.(*fakeSpan).fields
+		assert.Check(t, cmp.Equal(fields["outbox.event_type"], "build.created"))
+		lag, ok := fields["outbox.lag_ms"].(float64)
+		assert.Assert(t, ok)
+		assert.Check(t, lag >= 60_000, lag)
+	})
+
+	t.Run("zero created at", func(t *testing.T) {
+		ctx := WithProvider(context.Background(), &fakeProvider{})
+		ctx, span := StartSpan(ctx, "publish")
+
+		RecordOutboxPublish(ctx, "build.created", time.Time{})
+		assert.Check(t, cmp.DeepEqual(span.(*fakeSpan).fields, map[string]interface{}{
+			"outbox.event_type": "build.created",
+		}))
+	})
+}
@@ -0,0 +1,20 @@
+package o11y
+
+import (
+	"context"
+	"database/sql"
+)
+
+// RecordPoolStats adds the standard db.pool.open, db.pool.in_use, db.pool.idle and db.pool.wait_count
+// fields for the database connection pool stats to the currently active span, so that slow queries
+// caused by pool exhaustion are visible. The wait count is the total since the pool was opened.
+func RecordPoolStats(ctx context.Context, stats sql.DBStats) {
+	span := FromContext(ctx).GetSpan(ctx)
+	if span == nil {
+		return
+	}
+	span.AddRawField("db.pool.open", stats.OpenConnections)
+	span.AddRawField("db.pool.in_use", stats.InUse)
+	span.AddRawField("db.pool.idle", stats.Idle)
+	span.AddRawField("db.pool.wait_count", stats.WaitCount)
+}
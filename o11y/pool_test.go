@@ -0,0 +1,31 @@
+package o11y
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+func TestRecordPoolStats(t *testing.T) {
+	stats := sql.DBStats{OpenConnections: 5, InUse: 4, Idle: 1, WaitCount: 12}
+
+	t.Run("without provider", func(t *testing.T) {
+		RecordPoolStats(context.Background(), stats)
+	})
+
+	t.Run("adds fields", func(t *testing.T) {
+		ctx := WithProvider(context.Background(), &fakeProvider{})
+		ctx, span := StartSpan(ctx, "query")
+
+		RecordPoolStats(ctx, stats)
+		assert.Check(t, cmp.DeepEqual(span.(*fakeSpan).fields, map[string]interface{}{
+			"db.pool.open":       5,
+			"db.pool.in_use":     4,
+			"db.pool.idle":       1,
+			"db.pool.wait_count": int64(12),
+		}))
+	})
+}
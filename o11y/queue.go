@@ -0,0 +1,32 @@
+package o11y
+
+import (
+	"context"
+	"strings"
+)
+
+// RecordQueueDepth adds the depth of the named internal queue (e.g. a worker pool backlog) to the
+// currently active span as the standard queue.<name>.depth field, so that request latency can be
+// correlated with saturation at the time of the request. Dashes in the name are replaced with underscores.
+//
+// To also send the depth as a gauge metric as the span ends, record QueueDepthGauge on the span.
+func RecordQueueDepth(ctx context.Context, name string, depth int) {
+	span := FromContext(ctx).GetSpan(ctx)
+	if span == nil {
+		return
+	}
+	span.AddRawField(queueDepthField(name), depth)
+}
+
+// QueueDepthGauge returns the metric for a span to send the depth recorded by RecordQueueDepth as the
+// queue.<name>.depth gauge, e.g.
+//
+//	span.RecordMetric(o11y.QueueDepthGauge("builds"))
+func QueueDepthGauge(name string) Metric {
+	field := queueDepthField(name)
+	return Gauge(field, field)
+}
+
+func queueDepthField(name string) string {
+	return "queue." + strings.ReplaceAll(name, "-", "_") + ".depth"
+}
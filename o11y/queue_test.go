@@ -0,0 +1,33 @@
+package o11y
+
+import (
+	"context"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+func TestRecordQueueDepth(t *testing.T) {
+	t.Run("without provider", func(t *testing.T) {
+		RecordQueueDepth(context.Background(), "builds", 3)
+	})
+
+	t.Run("adds field", func(t *testing.T) {
+		ctx := WithProvider(context.Background(), &fakeProvider{})
+		ctx, span := StartSpan(ctx, "request")
+
+		RecordQueueDepth(ctx, "build-jobs", 42)
+		assert.Check(t, cmp.DeepEqual(span.(*fakeSpan).fields, map[string]interface{}{
+			"queue.build_jobs.depth": 42,
+		}))
+	})
+
+	t.Run("gauge", func(t *testing.T) {
+		assert.Check(t, cmp.DeepEqual(QueueDepthGauge("build-jobs"), Metric{
+			Type:  MetricGauge,
+			Name:  "queue.build_jobs.depth",
+			Field: "queue.build_jobs.depth",
+		}))
+	})
+}
@@ -0,0 +1,45 @@
+package o11y
+
+// Result is the outcome of the work done in a span, recorded in the "result" field.
+//
+// The constants below are the canonical set of results. Dashboards and SLO queries rely on these values,
+// so avoid recording free-form result strings; prefer AddResultToSpan, which picks the result for an error.
+type Result string
+
+const (
+	// ResultSuccess is the result of work that completed without error, including with a warning.
+	ResultSuccess Result = "success"
+	// ResultError is the result of work that failed.
+	ResultError Result = "error"
+	// ResultCanceled is the result of work abandoned due to context cancellation or a deadline.
+	ResultCanceled Result = "canceled"
+	// ResultPartial is the result of batch work where some, but not all, of the items failed.
+	ResultPartial Result = "partial"
+)
+
+// SetResult records the result field on the span.
+func SetResult(span Span, r Result) {
+	span.AddRawField("result", string(r))
+}
+
+// StatusCode is the canonical span status, as understood by OpenTelemetry backends. It is separate to
+// the Result, which is kept for backends that query the result field.
+type StatusCode int
+
+const (
+	StatusUnset StatusCode = iota
+	StatusOK
+	StatusError
+)
+
+// SetStatus sets the canonical status of the span, for providers that support it, along with a
+// description, which is typically only given for StatusError.
+func SetStatus(span Span, code StatusCode, description string) {
+	if s, ok := span.(statusSetter); ok {
+		s.SetStatus(code, description)
+	}
+}
+
+type statusSetter interface {
+	SetStatus(code StatusCode, description string)
+}
@@ -0,0 +1,75 @@
+package o11y
+
+import (
+	"context"
+	"encoding/json"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// SpanContext is a provider agnostic, serializable identity of a span. It can be passed between
+// processes (e.g. in a message body, or a database row) to link work back to the trace that
+// requested it, without importing any provider types.
+type SpanContext struct {
+	TraceID    string `json:"trace_id"`
+	SpanID     string `json:"span_id"`
+	Sampled    bool   `json:"sampled,omitempty"`
+	TraceState string `json:"trace_state,omitempty"`
+}
+
+// IsValid reports whether the span context has both a trace and span id.
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != "" && sc.SpanID != ""
+}
+
+// Marshal serializes the span context, for use with Unmarshal.
+func (sc SpanContext) Marshal() ([]byte, error) {
+	return json.Marshal(sc)
+}
+
+// Unmarshal sets the span context from data produced by Marshal.
+func (sc *SpanContext) Unmarshal(data []byte) error {
+	return json.Unmarshal(data, sc)
+}
+
+// SpanContextFromContext returns the span context of the currently active span. The zero
+// SpanContext is returned if there is no active span.
+func SpanContextFromContext(ctx context.Context) SpanContext {
+	span := FromContext(ctx).GetSpan(ctx)
+	if span == nil {
+		return SpanContext{}
+	}
+	return span.Context()
+}
+
+// ContextFrom returns a copy of ctx with sc as the remote parent, so that spans started from the
+// returned context continue the trace identified by sc. It is the counterpart of Span.Context.
+// If sc is not a valid W3C span context ctx is returned unchanged.
+//
+// N.B. this is only supported by the otel provider.
+func ContextFrom(ctx context.Context, sc SpanContext) context.Context {
+	traceID, err := trace.TraceIDFromHex(sc.TraceID)
+	if err != nil {
+		return ctx
+	}
+	spanID, err := trace.SpanIDFromHex(sc.SpanID)
+	if err != nil {
+		return ctx
+	}
+	ts, err := trace.ParseTraceState(sc.TraceState)
+	if err != nil {
+		return ctx
+	}
+	var flags trace.TraceFlags
+	if sc.Sampled {
+		flags = trace.FlagsSampled
+	}
+	otelSC := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: flags,
+		TraceState: ts,
+		Remote:     true,
+	})
+	return trace.ContextWithRemoteSpanContext(ctx, otelSC)
+}
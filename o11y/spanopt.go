@@ -2,6 +2,14 @@ package o11y
 
 type SpanConfig struct {
 	Kind SpanKind
+	// Fields are raw fields that are set as the span is started.
+	Fields []Pair
+	// NewRoot starts the span as the root of a new trace, even if the context has an active span.
+	NewRoot bool
+	// Links are span contexts of related spans, e.g. in other traces, to link the span to.
+	Links []SpanContext
+	// OnEnd are called with the span as it ends, before it is exported.
+	OnEnd []func(Span)
 }
 
 type SpanOpt func(SpanConfig) SpanConfig
@@ -14,6 +22,47 @@ func WithSpanKind(kind SpanKind) SpanOpt {
 	}
 }
 
+// WithStartFields adds raw fields to the span as it is started, rather than after it has started.
+// This makes them visible to head samplers, which make their sampling decision at span start.
+func WithStartFields(fields ...Pair) SpanOpt {
+	return func(cfg SpanConfig) SpanConfig {
+		cfg.Fields = append(cfg.Fields, fields...)
+		return cfg
+	}
+}
+
+// WithNewRoot starts the span as the root of a new trace, rather than as a child of any span active
+// in the context, e.g. for periodic work started from a long-lived context.
+func WithNewRoot() SpanOpt {
+	return func(cfg SpanConfig) SpanConfig {
+		cfg.NewRoot = true
+		return cfg
+	}
+}
+
+// WithLinks links the span to the spans identified by links, which may be in other traces or processes,
+// e.g. from a SpanContext stored with queued work. Invalid span contexts are ignored.
+//
+// N.B. this is only supported by the otel provider.
+func WithLinks(links ...SpanContext) SpanOpt {
+	return func(cfg SpanConfig) SpanConfig {
+		cfg.Links = append(cfg.Links, links...)
+		return cfg
+	}
+}
+
+// WithOnEnd calls fn with the span as it ends, before it is exported, so fn can add fields to it,
+// e.g. the time the span consumed from a budget, see WithBudget.
+//
+// N.B. this is only supported by the otel and honeycomb providers. For honeycomb, the span returned by
+// StartSpan must be the one ended.
+func WithOnEnd(fn func(Span)) SpanOpt {
+	return func(cfg SpanConfig) SpanConfig {
+		cfg.OnEnd = append(cfg.OnEnd, fn)
+		return cfg
+	}
+}
+
 // SpanKind is the role a Span plays in a Trace.
 type SpanKind int
 
@@ -0,0 +1,42 @@
+package o11y
+
+import (
+	"context"
+	"sync"
+)
+
+// StartStreamSpan starts a span for a long-lived streaming response, e.g. server sent events, returning
+// a func to call when the stream ends. If ctx is cancelled before then, e.g. because the client went
+// away, the span is ended at once, with the stream.abandoned field set to true, so that abandoned
+// streams are still recorded even if the handler is blocked. See RecordStreamProgress.
+func StartStreamSpan(ctx context.Context, name string, opts ...SpanOpt) (context.Context, func()) {
+	ctx, span := StartSpan(ctx, name, opts...)
+
+	var once sync.Once
+	end := func(abandoned bool) {
+		once.Do(func() {
+			if abandoned {
+				span.AddRawField("stream.abandoned", true)
+			}
+			span.End()
+		})
+	}
+	stop := context.AfterFunc(ctx, func() { end(true) })
+	return ctx, func() {
+		stop()
+		end(false)
+	}
+}
+
+// RecordStreamProgress adds the events and bytes delivered since the last call to the stream.events and
+// stream.bytes fields of the active span, so a still open stream shows how much it has delivered. The
+// totals are updated as they are recorded, so there is nothing to flush when the stream ends. It is
+// safe to call concurrently for providers that support accumulating fields, see AddToField.
+func RecordStreamProgress(ctx context.Context, events, bytes int64) {
+	span := FromContext(ctx).GetSpan(ctx)
+	if span == nil {
+		return
+	}
+	AddToField(span, "stream.events", events)
+	AddToField(span, "stream.bytes", bytes)
+}
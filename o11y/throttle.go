@@ -0,0 +1,25 @@
+package o11y
+
+import (
+	"context"
+	"time"
+)
+
+// RecordThrottle records that the current operation was rate limited or throttled, adding the standard
+// throttled, throttle.reason and throttle.retry_after_ms fields to the currently active span. The trace
+// is also flagged with the throttled trace field (see AddFieldToTrace), so throttled traces can be found
+// from any of their spans. The retry after field is omitted if retryAfter is zero.
+func RecordThrottle(ctx context.Context, reason string, retryAfter time.Duration) {
+	span := FromContext(ctx).GetSpan(ctx)
+	if span == nil {
+		return
+	}
+	span.AddRawField("throttled", true)
+	if reason != "" {
+		span.AddRawField("throttle.reason", reason)
+	}
+	if retryAfter > 0 {
+		span.AddRawField("throttle.retry_after_ms", retryAfter.Milliseconds())
+	}
+	AddFieldToTrace(ctx, "throttled", true)
+}
@@ -0,0 +1,28 @@
+package o11y
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+func TestRecordThrottle(t *testing.T) {
+	t.Run("without provider", func(t *testing.T) {
+		RecordThrottle(context.Background(), "rate limit", time.Second)
+	})
+
+	t.Run("adds fields", func(t *testing.T) {
+		ctx := WithProvider(context.Background(), &fakeProvider{})
+		ctx, span := StartSpan(ctx, "request")
+
+		RecordThrottle(ctx, "org rate limit", 1500*time.Millisecond)
+		assert.Check(t, cmp.DeepEqual(span.(*fakeSpan).fields, map[string]interface{}{
+			"throttled":               true,
+			"throttle.reason":         "org rate limit",
+			"throttle.retry_after_ms": int64(1500),
+		}))
+	})
+}
@@ -0,0 +1,60 @@
+package o11y
+
+import (
+	"context"
+	"errors"
+)
+
+// Error is an error that carries the span context of the span that was active when it was created,
+// so that an error surfacing far from its origin (e.g. logged at the top of the stack, or after
+// crossing a goroutine) can be attributed back to the span where it arose.
+type Error struct {
+	err error
+	sc  SpanContext
+}
+
+// NewError wraps err with the span context of the active span in ctx. A nil err returns nil.
+// If there is no active span, or err already carries a span context, err is returned unchanged,
+// so the originating span is kept.
+func NewError(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+	var e *Error
+	if errors.As(err, &e) {
+		return err
+	}
+	sc := SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return err
+	}
+	return &Error{err: err, sc: sc}
+}
+
+func (e *Error) Error() string {
+	return e.err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.err
+}
+
+// SpanContext returns the span context captured when the error was created.
+func (e *Error) SpanContext() SpanContext {
+	return e.sc
+}
+
+// SpanContextFromError returns the span context captured by the first Error in the chain of err.
+func SpanContextFromError(err error) (SpanContext, bool) {
+	var e *Error
+	if !errors.As(err, &e) {
+		return SpanContext{}, false
+	}
+	return e.sc, true
+}
+
+// TraceIDFromError returns the trace id captured by the first Error in the chain of err.
+func TraceIDFromError(err error) (string, bool) {
+	sc, ok := SpanContextFromError(err)
+	return sc.TraceID, ok
+}
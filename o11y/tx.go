@@ -0,0 +1,66 @@
+package o11y
+
+import (
+	"context"
+)
+
+// TxOutcome is the outcome of a transaction, recorded in the tx.outcome field.
+type TxOutcome string
+
+const (
+	TxCommitted  TxOutcome = "committed"
+	TxRolledBack TxOutcome = "rolled_back"
+	// TxUnknown is recorded if the span is ended without Commit or Rollback being called, which
+	// usually means a code path that does not record its rollback.
+	TxUnknown TxOutcome = "unknown"
+)
+
+// TxSpan is a span for the lifecycle of a transaction. It should be completed with exactly one of
+// Commit or Rollback, which end the span. Spans started from the context returned by StartTxSpan,
+// such as query spans, are nested within the transaction span.
+type TxSpan struct {
+	Span
+	done bool
+}
+
+// StartTxSpan starts a span for a transaction, e.g. a database transaction.
+func StartTxSpan(ctx context.Context, name string, opts ...SpanOpt) (context.Context, *TxSpan) {
+	ctx, span := StartSpan(ctx, name, opts...)
+	return ctx, &TxSpan{Span: span}
+}
+
+// Commit records that the transaction was committed, along with the result of the commit, and ends
+// the span. A failed commit is recorded as rolled back, since the transaction did not take effect.
+func (t *TxSpan) Commit(err error) {
+	outcome := TxCommitted
+	if err != nil {
+		outcome = TxRolledBack
+	}
+	t.finish(outcome, err)
+}
+
+// Rollback records that the transaction was rolled back, along with the result, typically the error
+// that caused the rollback, and ends the span.
+func (t *TxSpan) Rollback(err error) {
+	t.finish(TxRolledBack, err)
+}
+
+// End ends the span, recording an unknown outcome if neither Commit nor Rollback has been called.
+func (t *TxSpan) End() {
+	if t.done {
+		return
+	}
+	t.done = true
+	t.AddRawField("tx.outcome", string(TxUnknown))
+	t.Span.End()
+}
+
+func (t *TxSpan) finish(outcome TxOutcome, err error) {
+	if t.done {
+		return
+	}
+	t.done = true
+	t.AddRawField("tx.outcome", string(outcome))
+	AddResultToSpan(t.Span, err)
+	t.Span.End()
+}
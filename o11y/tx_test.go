@@ -0,0 +1,61 @@
+package o11y
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+func TestTxSpan(t *testing.T) {
+	t.Run("commit", func(t *testing.T) {
+		ctx := WithProvider(context.Background(), &fakeProvider{})
+		_, tx := StartTxSpan(ctx, "tx")
+		tx.Commit(nil)
+		tx.End()
+
+		span := tx.Span.(*fakeSpan)
+		assert.Check(t, span.ended)
+		assert.Check(t, cmp.Equal(span.fields["tx.outcome"], "committed"))
+		assert.Check(t, cmp.Equal(span.fields["result"], "success"))
+	})
+
+	t.Run("failed commit", func(t *testing.T) {
+		ctx := WithProvider(context.Background(), &fakeProvider{})
+		_, tx := StartTxSpan(ctx, "tx")
+		tx.Commit(errors.New("serialization failure"))
+
+		span := tx.Span.(*fakeSpan)
+		assert.Check(t, cmp.Equal(span.fields["tx.outcome"], "rolled_back"))
+		assert.Check(t, cmp.Equal(span.fields["result"], "error"))
+		assert.Check(t, cmp.Equal(span.fields["error"], "serialization failure"))
+	})
+
+	t.Run("rollback", func(t *testing.T) {
+		ctx := WithProvider(context.Background(), &fakeProvider{})
+		_, tx := StartTxSpan(ctx, "tx")
+		tx.Rollback(context.Canceled)
+		tx.Commit(nil)
+
+		span := tx.Span.(*fakeSpan)
+		assert.Check(t, cmp.Equal(span.fields["tx.outcome"], "rolled_back"))
+		assert.Check(t, cmp.Equal(span.fields["result"], "canceled"))
+	})
+
+	t.Run("unrecorded", func(t *testing.T) {
+		ctx := WithProvider(context.Background(), &fakeProvider{})
+		_, tx := StartTxSpan(ctx, "tx")
+		tx.End()
+
+		span := tx.Span.(*fakeSpan)
+		assert.Check(t, span.ended)
+		assert.Check(t, cmp.Equal(span.fields["tx.outcome"], "unknown"))
+	})
+
+	t.Run("without provider", func(t *testing.T) {
+		_, tx := StartTxSpan(context.Background(), "tx")
+		tx.Commit(nil)
+	})
+}
@@ -0,0 +1,40 @@
+package o11y
+
+import (
+	"context"
+	"sort"
+	"strings"
+)
+
+// maxValidationFields caps the fields recorded by RecordValidationErrors, since the field paths come
+// from the request, and arbitrarily many could be sent.
+const maxValidationFields = 20
+
+// RecordValidationErrors records the validation failures of a rejected request on the currently active
+// span, as validation.<field>=message fields, keyed by the path of each invalid field, plus the total
+// validation.error_count. This shows which fields failed without recording the request payload. Only the
+// first 20 fields (in sorted order) are recorded, in which case validation.truncated is set to true.
+// Dashes in the field paths are replaced with underscores.
+func RecordValidationErrors(ctx context.Context, errs map[string]string) {
+	if len(errs) == 0 {
+		return
+	}
+	span := FromContext(ctx).GetSpan(ctx)
+	if span == nil {
+		return
+	}
+
+	fields := make([]string, 0, len(errs))
+	for f := range errs {
+		fields = append(fields, f)
+	}
+	sort.Strings(fields)
+	if len(fields) > maxValidationFields {
+		fields = fields[:maxValidationFields]
+		span.AddRawField("validation.truncated", true)
+	}
+	for _, f := range fields {
+		span.AddRawField("validation."+strings.ReplaceAll(f, "-", "_"), errs[f])
+	}
+	span.AddRawField("validation.error_count", len(errs))
+}
@@ -0,0 +1,47 @@
+package o11y
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+func TestRecordValidationErrors(t *testing.T) {
+	t.Run("without provider", func(t *testing.T) {
+		RecordValidationErrors(context.Background(), map[string]string{"name": "required"})
+	})
+
+	t.Run("adds fields", func(t *testing.T) {
+		ctx := WithProvider(context.Background(), &fakeProvider{})
+		ctx, span := StartSpan(ctx, "request")
+
+		RecordValidationErrors(ctx, map[string]string{
+			"name":             "required",
+			"items[0].unit-id": "unknown unit",
+		})
+		assert.Check(t, cmp.DeepEqual(span.(*fakeSpan).fields, map[string]interface{}{
+			"validation.name":             "required",
+			"validation.items[0].unit_id": "unknown unit",
+			"validation.error_count":      2,
+		}))
+	})
+
+	t.Run("capped", func(t *testing.T) {
+		ctx := WithProvider(context.Background(), &fakeProvider{})
+		ctx, span := StartSpan(ctx, "request")
+
+		errs := map[string]string{}
+		for i := 0; i < 100; i++ {
+			errs[fmt.Sprintf("field%02d", i)] = "invalid"
+		}
+		RecordValidationErrors(ctx, errs)
+		fields := span.(*fakeSpan).fields
+		assert.Check(t, cmp.Len(fields, maxValidationFields+2))
+		assert.Check(t, cmp.Equal(fields["validation.error_count"], 100))
+		assert.Check(t, cmp.Equal(fields["validation.truncated"], true))
+		assert.Check(t, cmp.Equal(fields["validation.field19"], "invalid"))
+	})
+}
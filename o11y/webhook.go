@@ -0,0 +1,44 @@
+package o11y
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+)
+
+// RecordWebhookDelivery adds the standard webhook.url, webhook.status_code and webhook.attempt fields for
+// a webhook delivery attempt to the currently active span, and sets the result from the status code.
+// A 2xx status is a success, anything else, including 0 for no response, is an error.
+//
+// Webhook urls often embed secrets, so the query and any user info are removed from the url that is
+// recorded. Other parts of the url can be redacted by the provider, e.g. with otel.Config RedactPatterns.
+func RecordWebhookDelivery(ctx context.Context, webhookURL string, statusCode int, attempt int) {
+	span := FromContext(ctx).GetSpan(ctx)
+	if span == nil {
+		return
+	}
+	span.AddRawField("webhook.url", redactURL(webhookURL))
+	span.AddRawField("webhook.status_code", statusCode)
+	span.AddRawField("webhook.attempt", attempt)
+	if statusCode >= 200 && statusCode < 300 {
+		SetResult(span, ResultSuccess)
+		SetStatus(span, StatusOK, "")
+		return
+	}
+	SetResult(span, ResultError)
+	SetStatus(span, StatusError, fmt.Sprintf("webhook delivery failed with status %d", statusCode))
+}
+
+// redactURL removes the query and user info from u, which is dropped entirely if it cannot be parsed.
+func redactURL(u string) string {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return ""
+	}
+	parsed.User = nil
+	parsed.RawQuery = ""
+	parsed.ForceQuery = false
+	parsed.Fragment = ""
+	parsed.RawFragment = ""
+	return parsed.String()
+}
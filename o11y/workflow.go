@@ -0,0 +1,32 @@
+package o11y
+
+import (
+	"context"
+)
+
+// StartWorkflowStep starts a span for a step of a long-running workflow (e.g. a saga), named
+// "workflow: <workflow>.<step>", with the standard workflow.name and workflow.step fields. Steps often
+// run in different processes, long after the previous step, so rather than continuing one trace the span
+// is linked to the spans of any previous steps, e.g. from the Span.Context of each stored with the
+// workflow state. Store the Context of the returned span for the next step in turn.
+func StartWorkflowStep(ctx context.Context, workflow, step string, previous ...SpanContext) (context.Context, Span) {
+	return StartSpan(ctx, "workflow: "+workflow+"."+step,
+		WithLinks(previous...),
+		WithStartFields(
+			Field("workflow.name", workflow),
+			Field("workflow.step", step),
+		),
+	)
+}
+
+// RecordWorkflowStep adds the standard workflow.name, workflow.step and workflow.step_status fields to
+// the currently active span, for a step of a long-running workflow, see StartWorkflowStep.
+func RecordWorkflowStep(ctx context.Context, workflow, step, status string) {
+	span := FromContext(ctx).GetSpan(ctx)
+	if span == nil {
+		return
+	}
+	span.AddRawField("workflow.name", workflow)
+	span.AddRawField("workflow.step", step)
+	span.AddRawField("workflow.step_status", status)
+}
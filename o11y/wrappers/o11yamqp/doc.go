@@ -0,0 +1,4 @@
+/*
+Package o11yamqp contains helpers for propagating traces across AMQP (RabbitMQ) brokers.
+*/
+package o11yamqp
@@ -0,0 +1,75 @@
+package o11yamqp
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+
+	"github.com/circleci/ex/o11y"
+)
+
+// StartPublishSpan starts a producer span for publishing msg to the exchange with the routing key,
+// and injects the trace propagation headers into the msg headers, so that consumers of the message
+// can continue the trace. The caller is responsible for ending the span once the message is published.
+func StartPublishSpan(ctx context.Context, exchange, key string, msg *amqp.Publishing) (context.Context, o11y.Span) {
+	ctx, span := o11y.StartSpan(ctx, "amqp publish: "+exchange, o11y.WithSpanKind(o11y.SpanKindProducer))
+	addFields(span, "publish", exchange, key, msg.MessageId, msg.CorrelationId)
+
+	msg.Headers = Inject(ctx, msg.Headers)
+	return ctx, span
+}
+
+// StartConsumeSpan starts a consumer span for the delivery from the queue, continuing the trace from
+// the propagation headers in the delivery, if there are any. The caller is responsible for ending the
+// span once the delivery has been processed.
+func StartConsumeSpan(ctx context.Context, queue string, d amqp.Delivery) (context.Context, o11y.Span) {
+	p := o11y.FromContext(ctx)
+	ctx, span := p.Helpers().InjectPropagation(ctx, Extract(d.Headers), o11y.WithSpanKind(o11y.SpanKindConsumer))
+	span.AddRawField("name", "amqp consume: "+queue)
+	addFields(span, "process", d.Exchange, d.RoutingKey, d.MessageId, d.CorrelationId)
+	span.AddRawField("messaging.source.name", queue)
+	return ctx, span
+}
+
+// Inject adds the trace propagation headers from the context to the message headers.
+// The headers are returned, since a new table is made if headers is nil.
+func Inject(ctx context.Context, headers amqp.Table) amqp.Table {
+	prop := o11y.FromContext(ctx).Helpers().ExtractPropagation(ctx)
+	if len(prop.Headers) == 0 {
+		return headers
+	}
+	if headers == nil {
+		headers = amqp.Table{}
+	}
+	for k := range prop.Headers {
+		// http headers are canonicalised, but the propagation headers are conventionally lower case
+		headers[strings.ToLower(k)] = prop.Headers.Get(k)
+	}
+	return headers
+}
+
+// Extract returns the trace propagation context from the message headers, for use with InjectPropagation.
+func Extract(headers amqp.Table) o11y.PropagationContext {
+	h := http.Header{}
+	for k, v := range headers {
+		if s, ok := v.(string); ok {
+			h.Set(k, s)
+		}
+	}
+	return o11y.PropagationContextFromHeader(h)
+}
+
+func addFields(span o11y.Span, operation, exchange, key, messageID, correlationID string) {
+	span.AddRawField("messaging.system", "rabbitmq")
+	span.AddRawField("messaging.operation", operation)
+	span.AddRawField("messaging.destination.name", exchange)
+	span.AddRawField("messaging.rabbitmq.destination.routing_key", key)
+	if messageID != "" {
+		span.AddRawField("messaging.message.id", messageID)
+	}
+	if correlationID != "" {
+		span.AddRawField("messaging.message.conversation_id", correlationID)
+	}
+}
@@ -0,0 +1,53 @@
+package o11yamqp
+
+import (
+	"context"
+	"testing"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+
+	"github.com/circleci/ex/internal/syncbuffer"
+	"github.com/circleci/ex/o11y"
+	"github.com/circleci/ex/o11y/otel"
+)
+
+func TestPropagation(t *testing.T) {
+	var b syncbuffer.SyncBuffer
+	provider, err := otel.New(otel.Config{Writer: &b})
+	assert.NilError(t, err)
+	ctx := o11y.WithProvider(context.Background(), provider)
+
+	msg := amqp.Publishing{MessageId: "msg-1"}
+	pubCtx, pubSpan := StartPublishSpan(ctx, "jobs", "job.created", &msg)
+	pubSpan.End()
+	assert.Check(t, msg.Headers["traceparent"] != nil)
+
+	// as if on the other side of the broker
+	d := amqp.Delivery{
+		Headers:    msg.Headers,
+		Exchange:   "jobs",
+		RoutingKey: "job.created",
+		MessageId:  msg.MessageId,
+	}
+	_, conSpan := StartConsumeSpan(ctx, "job-queue", d)
+	conSpan.End()
+	provider.Close(ctx)
+
+	pubTrace := o11y.SpanContextFromContext(pubCtx).TraceID
+	assert.Check(t, cmp.Equal(conSpan.Context().TraceID, pubTrace))
+
+	out := b.String()
+	assert.Check(t, cmp.Contains(out, "amqp publish: jobs "+
+		"messaging.destination.name=jobs messaging.message.id=msg-1 messaging.operation=publish "+
+		"messaging.rabbitmq.destination.routing_key=job.created messaging.system=rabbitmq\n"))
+	assert.Check(t, cmp.Contains(out, "amqp consume: job-queue "+
+		"messaging.destination.name=jobs messaging.message.id=msg-1 messaging.operation=process "+
+		"messaging.rabbitmq.destination.routing_key=job.created messaging.source.name=job-queue "+
+		"messaging.system=rabbitmq\n"))
+}
+
+func TestInject_NoTrace(t *testing.T) {
+	assert.Check(t, cmp.Nil(Inject(context.Background(), nil)))
+}
@@ -25,6 +25,7 @@ func Middleware(provider o11y.Provider, serverName string, queryParams map[strin
 		before := time.Now()
 
 		ctx := o11y.WithProvider(c.Request.Context(), provider)
+		ctx = o11y.WithRequestID(ctx, c.Request.Header.Get(o11y.RequestIDHeader))
 		ctx = o11y.WithBaggage(ctx, baggage.Get(ctx, c.Request))
 		ctx, span := startSpanOrTraceFromHTTP(ctx, c, provider, serverName)
 		defer span.End()
@@ -143,6 +144,23 @@ func Recovery() func(c *gin.Context) {
 	})
 }
 
+// Headers is a gin middleware that records the named request and response headers on the active
+// span, as http.request.header.* and http.response.header.* fields, see o11y.AddHeaderFields. It
+// should be used after Middleware.
+func Headers(request, response []string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		span := o11y.FromContext(ctx).GetSpan(ctx)
+		if span == nil {
+			c.Next()
+			return
+		}
+		o11y.AddHeaderFields(span, "http.request.header", c.Request.Header, request)
+		defer o11y.AddHeaderFields(span, "http.response.header", c.Writer.Header(), response)
+		c.Next()
+	}
+}
+
 func Golden(p o11y.Provider) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		p.MakeSpanGolden(c.Request.Context())
@@ -26,6 +26,7 @@ import (
 	"github.com/circleci/ex/internal/syncbuffer"
 	"github.com/circleci/ex/o11y"
 	"github.com/circleci/ex/o11y/honeycomb"
+	"github.com/circleci/ex/o11y/otel"
 	"github.com/circleci/ex/testing/fakemetrics"
 	"github.com/circleci/ex/testing/jaeger"
 	"github.com/circleci/ex/testing/testcontext"
@@ -464,6 +465,33 @@ func TestRenderError(t *testing.T) {
 	assert.Check(t, cmp.Contains(buf.String(), "app.gin_internal_error"))
 }
 
+func TestHeaders(t *testing.T) {
+	var b syncbuffer.SyncBuffer
+	provider, err := otel.New(otel.Config{Writer: &b})
+	assert.NilError(t, err)
+
+	r := gin.New()
+	r.Use(
+		Middleware(provider, "test-server", nil),
+		Headers([]string{"X-Request-ID"}, []string{"Cache-Control"}),
+	)
+	r.GET("/", func(c *gin.Context) {
+		c.Header("Cache-Control", "no-store")
+		c.Status(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-Request-ID", "abc")
+	req.Header.Set("Authorization", "Bearer secret")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+	provider.Close(context.Background())
+
+	out := b.String()
+	assert.Check(t, cmp.Contains(out, "http.request.header.x_request_id=abc "))
+	assert.Check(t, cmp.Contains(out, "http.response.header.cache_control=no-store "))
+	assert.Check(t, !strings.Contains(out, "secret"))
+}
+
 type errorRenderer struct{}
 
 func (e errorRenderer) Render(_ http.ResponseWriter) error {
@@ -3,6 +3,7 @@ package o11ynethttp
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
@@ -15,19 +16,76 @@ import (
 
 type nethttpRouteRecorderContextKey struct{}
 
+// Option configures optional behaviour of the Middleware.
+type Option func(*options)
+
+type options struct {
+	pathRules     []PathRule
+	recoverPanics bool
+	repanic       bool
+	reqHeaders    []string
+	respHeaders   []string
+	extended      bool
+}
+
+// WithPanicRecovery recovers panics in the handler, recording the panic and stack on the span,
+// marking the span as errored and writing a 500 response if the handler has not already written
+// a response. If repanic is true the panic is propagated after the span is complete, for instance
+// to let the server log it, otherwise the panic is swallowed.
+//
+// Panics with http.ErrAbortHandler are always propagated, since that is how a handler aborts a response.
+func WithPanicRecovery(repanic bool) Option {
+	return func(o *options) {
+		o.recoverPanics = true
+		o.repanic = repanic
+	}
+}
+
+// WithHeaders records the named request and response headers on the span, as http.request.header.*
+// and http.response.header.* fields, see o11y.AddHeaderFields. No headers are recorded by default.
+func WithHeaders(request, response []string) Option {
+	return func(o *options) {
+		o.reqHeaders = request
+		o.respHeaders = response
+	}
+}
+
+// WithExtendedFields records the extended HTTP fields on the span, which are not recorded by default to
+// keep the span lean. These are the http.request.content_type, http.response.content_type and
+// http.response.content_encoding fields, for debugging serialization and compression. Each is omitted
+// if the header is not set.
+func WithExtendedFields() Option {
+	return func(o *options) {
+		o.extended = true
+	}
+}
+
 // Middleware returns an http.Handler which wraps an http.Handler and adds
 // an o11y.Provider to the context. A new span is created from the request headers.
 //
 // This code is based on github.com/beeline-go/wrappers/hnynethttp/nethttp.go
-func Middleware(provider o11y.Provider, name string, handler http.Handler) http.Handler {
+func Middleware(provider o11y.Provider, name string, handler http.Handler, opts ...Option) http.Handler {
+	o := options{}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		before := time.Now()
 
-		ctx, span := startSpanOrTraceFromHTTP(r, provider, name)
+		path := r.URL.Path
+		route := "unknown"
+		if o.pathRules != nil {
+			path = templatePath(o.pathRules, r.URL.Path)
+			route = path
+		}
+
+		ctx, span := startSpanOrTraceFromHTTP(r, provider, name, path)
 		defer span.End()
 
 		provider.AddFieldToTrace(ctx, "server_name", name)
 		routeRecorder := NewRouteRecorder()
+		routeRecorder.SetRoute(route)
 		ctx = o11y.WithProvider(ctx, provider)
 		ctx = o11y.WithBaggage(ctx, baggage.Get(ctx, r))
 		ctx = context.WithValue(ctx, nethttpRouteRecorderContextKey{}, routeRecorder)
@@ -36,15 +94,30 @@ func Middleware(provider o11y.Provider, name string, handler http.Handler) http.
 
 		// We default to using the Path as the name and route - which could be high cardinality
 		// We expect consumers to override these fields if they have something better
-		span.AddRawField("name", fmt.Sprintf("http-server %s: %s %s", name, r.Method, r.URL.Path))
-		span.AddRawField("request.route", "unknown")
+		span.AddRawField("name", fmt.Sprintf("http-server %s: %s %s", name, r.Method, path))
+		span.AddRawField("request.route", route)
+		if o.pathRules != nil {
+			span.AddRawField("http.target", r.URL.Path)
+		}
+		o11y.AddHeaderFields(span, "http.request.header", r.Header, o.reqHeaders)
+		if o.extended {
+			addHeaderField(span, "http.request.content_type", r.Header, "Content-Type")
+		}
 
 		sw := &statusWriter{ResponseWriter: w}
-		handler.ServeHTTP(sw, r)
+		if p := o.serve(span, handler, sw, r); p != nil {
+			// propagate the panic once the span is complete
+			defer panic(p)
+		}
 		if sw.status == 0 {
 			sw.status = 200
 		}
 		span.AddRawField("response.status_code", sw.status)
+		o11y.AddHeaderFields(span, "http.response.header", sw.Header(), o.respHeaders)
+		if o.extended {
+			addHeaderField(span, "http.response.content_type", sw.Header(), "Content-Type")
+			addHeaderField(span, "http.response.content_encoding", sw.Header(), "Content-Encoding")
+		}
 
 		m := provider.MetricsProvider()
 		if m != nil {
@@ -63,6 +136,47 @@ func Middleware(provider o11y.Provider, name string, handler http.Handler) http.
 	})
 }
 
+func addHeaderField(span o11y.Span, key string, h http.Header, name string) {
+	if v := h.Get(name); v != "" {
+		span.AddRawField(key, v)
+	}
+}
+
+// serve calls the handler, recovering any panic if configured to. Any recovered panic that should
+// be propagated is returned.
+func (o options) serve(span o11y.Span, handler http.Handler, w *statusWriter, r *http.Request) (repanic any) {
+	if !o.recoverPanics {
+		handler.ServeHTTP(w, r)
+		return nil
+	}
+
+	defer func() {
+		p := recover()
+		if p == nil {
+			return
+		}
+
+		// Most likely caused by one side of the proxy disappearing. Not really a panic
+		// https://github.com/golang/go/issues/28239
+		if err, ok := p.(error); ok && errors.Is(err, http.ErrAbortHandler) {
+			o11y.AddResultToSpan(span, err)
+			repanic = p
+			return
+		}
+
+		o11y.AddResultToSpan(span, o11y.HandlePanic(r.Context(), span, p, r))
+		if w.status == 0 {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		if o.repanic {
+			repanic = p
+		}
+	}()
+
+	handler.ServeHTTP(w, r)
+	return nil
+}
+
 type RouteRecorder struct {
 	route string
 	mu    sync.RWMutex
@@ -113,12 +227,21 @@ func (w *statusWriter) WriteHeader(status int) {
 	w.ResponseWriter.WriteHeader(status)
 }
 
-func startSpanOrTraceFromHTTP(req *http.Request, p o11y.Provider, serverName string) (context.Context, o11y.Span) {
-	ctx := req.Context()
+func (w *statusWriter) Write(b []byte) (int, error) {
+	// an implicit 200 is sent with the first write, if WriteHeader has not been called
+	w.once.Do(func() {
+		w.status = http.StatusOK
+	})
+	return w.ResponseWriter.Write(b)
+}
+
+func startSpanOrTraceFromHTTP(req *http.Request, p o11y.Provider,
+	serverName, path string) (context.Context, o11y.Span) {
+	ctx := o11y.WithRequestID(req.Context(), req.Header.Get(o11y.RequestIDHeader))
 	span := p.GetSpan(ctx)
 	// We default to using the Path as the name and route - which could be high cardinality
 	// We expect consumers to override these fields if they have something better
-	name := fmt.Sprintf("http-server %s: %s %s", serverName, req.Method, req.URL.Path)
+	name := fmt.Sprintf("http-server %s: %s %s", serverName, req.Method, path)
 	if span == nil {
 		// there is no trace yet. We should make one! and use the root span.
 		ctx, span := p.Helpers().InjectPropagation(ctx, o11y.PropagationContextFromHeader(req.Header))
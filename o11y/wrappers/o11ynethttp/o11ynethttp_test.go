@@ -4,6 +4,8 @@ import (
 	"context"
 	"io"
 	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/google/go-cmp/cmp/cmpopts"
@@ -14,8 +16,10 @@ import (
 
 	"github.com/circleci/ex/httpclient"
 	"github.com/circleci/ex/httpserver"
+	"github.com/circleci/ex/internal/syncbuffer"
 	"github.com/circleci/ex/o11y"
 	"github.com/circleci/ex/o11y/honeycomb"
+	"github.com/circleci/ex/o11y/otel"
 	"github.com/circleci/ex/testing/fakemetrics"
 )
 
@@ -278,3 +282,140 @@ func TestMiddleware_with_sampling(t *testing.T) {
 		assert.Check(t, httpclient.HasStatusCode(err, http.StatusNotFound))
 	})
 }
+
+func TestMiddleware_RequestID(t *testing.T) {
+	provider := honeycomb.New(honeycomb.Config{Format: "none"})
+	defer provider.Close(context.Background())
+
+	var got string
+	h := Middleware(provider, "test-server", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = o11y.RequestID(r.Context())
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(o11y.RequestIDHeader, "incoming-id")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	assert.Check(t, cmp.Equal(got, "incoming-id"))
+}
+
+func TestMiddleware_PathTemplates(t *testing.T) {
+	var b syncbuffer.SyncBuffer
+	provider, err := otel.New(otel.Config{Writer: &b})
+	assert.NilError(t, err)
+
+	h := Middleware(provider, "test-server", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}),
+		WithPathTemplates(),
+	)
+
+	req := httptest.NewRequest(http.MethodGet, "/users/123/posts/6ba7b810-9dad-11d1-80b4-00c04fd430c8/v2", nil)
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	provider.Close(context.Background())
+
+	out := b.String()
+	assert.Check(t, cmp.Contains(out, "http-server test-server: GET /users/:id/posts/:id/v2 "))
+	assert.Check(t, cmp.Contains(out, "http.target=/users/123/posts/6ba7b810-9dad-11d1-80b4-00c04fd430c8/v2 "))
+	assert.Check(t, cmp.Contains(out, "request.route=/users/:id/posts/:id/v2 "))
+}
+
+func TestMiddleware_Headers(t *testing.T) {
+	var b syncbuffer.SyncBuffer
+	provider, err := otel.New(otel.Config{Writer: &b})
+	assert.NilError(t, err)
+
+	h := Middleware(provider, "test-server", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-store")
+	}), WithHeaders([]string{"X-Request-ID"}, []string{"Cache-Control"}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-Request-ID", "abc")
+	req.Header.Set("Authorization", "Bearer secret")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	provider.Close(context.Background())
+
+	out := b.String()
+	assert.Check(t, cmp.Contains(out, "http.request.header.x_request_id=abc "))
+	assert.Check(t, cmp.Contains(out, "http.response.header.cache_control=no-store "))
+	assert.Check(t, !strings.Contains(out, "secret"))
+}
+
+func TestMiddleware_ExtendedFields(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Encoding", "gzip")
+	})
+	req := func() *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return req
+	}
+
+	t.Run("default", func(t *testing.T) {
+		var b syncbuffer.SyncBuffer
+		provider, err := otel.New(otel.Config{Writer: &b})
+		assert.NilError(t, err)
+
+		Middleware(provider, "test-server", handler).ServeHTTP(httptest.NewRecorder(), req())
+		provider.Close(context.Background())
+		assert.Check(t, !strings.Contains(b.String(), "content_type"))
+	})
+
+	t.Run("extended", func(t *testing.T) {
+		var b syncbuffer.SyncBuffer
+		provider, err := otel.New(otel.Config{Writer: &b})
+		assert.NilError(t, err)
+
+		Middleware(provider, "test-server", handler, WithExtendedFields()).
+			ServeHTTP(httptest.NewRecorder(), req())
+		provider.Close(context.Background())
+
+		out := b.String()
+		assert.Check(t, cmp.Contains(out, "http.request.content_type=application/x-www-form-urlencoded "))
+		assert.Check(t, cmp.Contains(out, "http.response.content_encoding=gzip "))
+		assert.Check(t, cmp.Contains(out, "http.response.content_type=application/json "))
+	})
+}
+
+func TestMiddleware_PanicRecovery(t *testing.T) {
+	panicky := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("oh noes!")
+	})
+
+	t.Run("recovered", func(t *testing.T) {
+		var b syncbuffer.SyncBuffer
+		provider, err := otel.New(otel.Config{Writer: &b})
+		assert.NilError(t, err)
+
+		rec := httptest.NewRecorder()
+		Middleware(provider, "test-server", panicky, WithPanicRecovery(false)).
+			ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		provider.Close(context.Background())
+
+		assert.Check(t, cmp.Equal(rec.Code, http.StatusInternalServerError))
+		out := b.String()
+		assert.Check(t, cmp.Contains(out, "error=panic handled: oh noes! "))
+		assert.Check(t, cmp.Contains(out, "has_panicked=true "))
+		assert.Check(t, cmp.Contains(out, "response.status_code=500 "))
+		assert.Check(t, cmp.Contains(out, "result=error "))
+		assert.Check(t, cmp.Contains(out, "stack=goroutine "))
+	})
+
+	t.Run("repanic", func(t *testing.T) {
+		var b syncbuffer.SyncBuffer
+		provider, err := otel.New(otel.Config{Writer: &b})
+		assert.NilError(t, err)
+
+		rec := httptest.NewRecorder()
+		func() {
+			defer func() {
+				assert.Check(t, cmp.Equal(recover(), "oh noes!"))
+			}()
+			Middleware(provider, "test-server", panicky, WithPanicRecovery(true)).
+				ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		}()
+		provider.Close(context.Background())
+
+		assert.Check(t, cmp.Equal(rec.Code, http.StatusInternalServerError))
+		assert.Check(t, cmp.Contains(b.String(), "response.status_code=500 "))
+	})
+}
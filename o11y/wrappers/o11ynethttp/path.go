@@ -0,0 +1,49 @@
+package o11ynethttp
+
+import (
+	"regexp"
+	"strings"
+)
+
+// PathRule replaces any path segment that wholly matches Pattern with Placeholder.
+type PathRule struct {
+	Pattern     *regexp.Regexp
+	Placeholder string
+}
+
+// DefaultPathRules replace numeric and UUID path segments with :id
+func DefaultPathRules() []PathRule {
+	return []PathRule{
+		{Pattern: regexp.MustCompile(`^[0-9]+$`), Placeholder: ":id"},
+		{
+			Pattern:     regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`),
+			Placeholder: ":id",
+		},
+	}
+}
+
+// WithPathTemplates reduces the cardinality of the span name and request.route by replacing
+// path segments that match the rules (DefaultPathRules if none are given) with placeholders,
+// e.g. /users/123/posts/456 becomes /users/:id/posts/:id. The full path is kept in the
+// http.target field. A route set via the RouteRecorder still takes precedence for metrics.
+func WithPathTemplates(rules ...PathRule) Option {
+	if len(rules) == 0 {
+		rules = DefaultPathRules()
+	}
+	return func(o *options) {
+		o.pathRules = rules
+	}
+}
+
+func templatePath(rules []PathRule, path string) string {
+	segments := strings.Split(path, "/")
+	for i, seg := range segments {
+		for _, r := range rules {
+			if r.Pattern.MatchString(seg) {
+				segments[i] = r.Placeholder
+				break
+			}
+		}
+	}
+	return strings.Join(segments, "/")
+}
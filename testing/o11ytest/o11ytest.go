@@ -0,0 +1,54 @@
+/*
+Package o11ytest provides helpers for making assertions about the o11y output of the code under test.
+*/
+package o11ytest
+
+import (
+	"context"
+	"encoding/binary"
+	"sync"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// IDGenerator is an otel IDGenerator that produces predictable, sequential trace and span ids,
+// starting from 1. Use it as the otel.Config IDGenerator, so that golden tests of trace output
+// are stable across runs.
+type IDGenerator struct {
+	mu      sync.Mutex
+	traceID uint64
+	spanID  uint64
+}
+
+// NewIDGenerator returns a new IDGenerator, it should not be shared between tests.
+func NewIDGenerator() *IDGenerator {
+	return &IDGenerator{}
+}
+
+// NewIDs returns the next trace id and span id for a new root span.
+func (g *IDGenerator) NewIDs(_ context.Context) (trace.TraceID, trace.SpanID) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.traceID++
+	g.spanID++
+
+	var tid trace.TraceID
+	binary.BigEndian.PutUint64(tid[8:], g.traceID)
+	return tid, g.spanIDLocked()
+}
+
+// NewSpanID returns the next span id for a new child span.
+func (g *IDGenerator) NewSpanID(_ context.Context, _ trace.TraceID) trace.SpanID {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.spanID++
+	return g.spanIDLocked()
+}
+
+func (g *IDGenerator) spanIDLocked() trace.SpanID {
+	var sid trace.SpanID
+	binary.BigEndian.PutUint64(sid[:], g.spanID)
+	return sid
+}
@@ -0,0 +1,46 @@
+package o11ytest
+
+import (
+	"context"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+
+	"github.com/circleci/ex/internal/syncbuffer"
+	"github.com/circleci/ex/o11y"
+	"github.com/circleci/ex/o11y/otel"
+)
+
+func TestIDGenerator(t *testing.T) {
+	op, err := otel.New(otel.Config{
+		Writer:      &syncbuffer.SyncBuffer{},
+		IDGenerator: NewIDGenerator(),
+	})
+	assert.NilError(t, err)
+	ctx := o11y.WithProvider(context.Background(), op)
+	defer op.Close(ctx)
+
+	ctx, root := o11y.StartSpan(ctx, "root")
+	defer root.End()
+	_, child := o11y.StartSpan(ctx, "child")
+	defer child.End()
+	_, other := o11y.StartSpan(o11y.WithProvider(context.Background(), op), "other")
+	defer other.End()
+
+	assert.Check(t, cmp.DeepEqual(root.Context(), o11y.SpanContext{
+		TraceID: "00000000000000000000000000000001",
+		SpanID:  "0000000000000001",
+		Sampled: true,
+	}))
+	assert.Check(t, cmp.DeepEqual(child.Context(), o11y.SpanContext{
+		TraceID: "00000000000000000000000000000001",
+		SpanID:  "0000000000000002",
+		Sampled: true,
+	}))
+	assert.Check(t, cmp.DeepEqual(other.Context(), o11y.SpanContext{
+		TraceID: "00000000000000000000000000000002",
+		SpanID:  "0000000000000003",
+		Sampled: true,
+	}))
+}